@@ -0,0 +1,118 @@
+package uidgo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrGeneratorClosed is returned by a generator after Close
+var ErrGeneratorClosed = errors.New("uidgo: generator is closed")
+
+// how long fill pauses after a failed generation before it tries again
+const bufferRetryDelay = time.Millisecond
+
+// BufferedGenerator generates ids ahead of time into a buffer, so GenerateId doesn't wait on the generator lock.
+//
+// Buffered ids are already minted: the underlying generator has moved past them, and a state persisted from it
+// covers them too. Ids that are never handed out are a gap in the id space, never reused, so the output stays
+// unique and increasing. Flush reports that gap.
+//
+// A failed generation, like the clock moving backwards, is handed to a single GenerateId call, and filling goes on
+// after a short pause, so the buffer recovers with the underlying generator.
+type BufferedGenerator struct {
+	g    *SnowflakeSeqGenerator
+	ids  chan bufferedId
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	// the id, if any, generated while fill was stopped, it never reached the buffer
+	pending []uint64
+}
+
+// bufferedId is an id, or the error of a failed generation, in the buffer
+type bufferedId struct {
+	id  uint64
+	err error
+}
+
+// NewBufferedGenerator starts filling a buffer of size ids from g
+func NewBufferedGenerator(g *SnowflakeSeqGenerator, size int) (*BufferedGenerator, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("buffer size(%d) should be at least 1", size)
+	}
+	B := &BufferedGenerator{
+		g:    g,
+		ids:  make(chan bufferedId, size),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go B.fill()
+	return B, nil
+}
+
+func (B *BufferedGenerator) fill() {
+	defer close(B.done)
+	defer close(B.ids)
+
+	for {
+		id, err := B.g.GenerateId2()
+		if errors.Is(err, ErrGeneratorClosed) {
+			return
+		}
+		if err != nil {
+			select {
+			case B.ids <- bufferedId{err: err}:
+			case <-B.stop:
+				return
+			}
+			select {
+			case <-time.After(bufferRetryDelay):
+			case <-B.stop:
+				return
+			}
+			continue
+		}
+		select {
+		case B.ids <- bufferedId{id: id}:
+		case <-B.stop:
+			B.pending = append(B.pending, id)
+			return
+		}
+	}
+}
+
+// GenerateId returns the next buffered id, or the error of a failed generation in its place. Once the buffer
+// is drained after Flush, Close or closing the underlying generator it returns ErrGeneratorClosed.
+func (B *BufferedGenerator) GenerateId() (uint64, error) {
+	b, ok := <-B.ids
+	if !ok {
+		return 0, ErrGeneratorClosed
+	}
+	return b.id, b.err
+}
+
+// Flush stops filling the buffer and returns the ids that were minted but never handed out, in order.
+// Those ids are discarded: later ids of the underlying generator are greater than all of them.
+func (B *BufferedGenerator) Flush() []uint64 {
+	B.once.Do(func() { close(B.stop) })
+	<-B.done
+
+	var gap []uint64
+	for b := range B.ids {
+		if b.err == nil {
+			gap = append(gap, b.id)
+		}
+	}
+	gap = append(gap, B.pending...)
+	B.pending = nil
+	return gap
+}
+
+// Close stops filling the buffer and discards the ids left in it
+func (B *BufferedGenerator) Close() error {
+	B.Flush()
+	return nil
+}
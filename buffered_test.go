@@ -0,0 +1,100 @@
+package uidgo_test
+
+import (
+	"testing"
+	"time"
+	"uidgo"
+)
+
+func TestBufferedGenerator_Flush(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	buffered, err := uidgo.NewBufferedGenerator(generator, 8)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var ids []uint64
+	for i := 0; i < 3; i++ {
+		id, err := buffered.GenerateId()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	// let the buffer fill up again
+	time.Sleep(50 * time.Millisecond)
+	gap := buffered.Flush()
+	if len(gap) < 8 {
+		t.Errorf("flushed %d ids, want the 8 of the full buffer", len(gap))
+	}
+
+	if _, err = buffered.GenerateId(); err != uidgo.ErrGeneratorClosed {
+		t.Errorf("error(%v) should be ErrGeneratorClosed after Flush", err)
+	}
+	next, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// handed out ids, then the gap, then the underlying generator keep increasing
+	ids = append(append(ids, gap...), next)
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Errorf("ids[%d](%d) should be greater than ids[%d](%d)", i, ids[i], i-1, ids[i-1])
+		}
+	}
+	if err = buffered.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBufferedGenerator_Recover(t *testing.T) {
+	clock := newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	buffered, err := uidgo.NewBufferedGenerator(generator, 2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer buffered.Close()
+	last, err := buffered.GenerateId()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the ids minted before the clock moved back drain first
+	clock.Add(-10 * time.Millisecond)
+	for i := 0; err == nil && i < 10; i++ {
+		var id uint64
+		if id, err = buffered.GenerateId(); err == nil {
+			last = id
+		}
+	}
+	if err == nil {
+		t.Error("the clock moving backwards should reach a GenerateId call")
+		return
+	}
+
+	clock.Add(20 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		var id uint64
+		if id, err = buffered.GenerateId(); err == nil {
+			if id <= last {
+				t.Errorf("id(%d) should be greater than the last id(%d)", id, last)
+			}
+			return
+		}
+	}
+	t.Errorf("the buffer should recover with the clock, last error is %v", err)
+}
@@ -0,0 +1,126 @@
+package uidgo
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeSource supplies the current time in unix milliseconds
+type TimeSource interface {
+	UnixMilli() int64
+}
+
+// systemClock reads the wall clock, it is the default TimeSource
+type systemClock struct{}
+
+func (systemClock) UnixMilli() int64 {
+	return time.Now().UnixMilli()
+}
+
+// ClockStrategy decides what the generator does when the time source reads before the last id's timestamp
+type ClockStrategy int
+
+const (
+	// StrategyError refuses to generate until the clock is past the last timestamp again, it is the default.
+	// Ids never run ahead of the clock, at the cost of failing every call for as long as the clock is behind.
+	StrategyError ClockStrategy = iota
+	// StrategyWait blocks, holding the generator lock, until the clock catches up or MaxWait passes, then fails.
+	// Ids never run ahead of the clock, a zero MaxWait waits however long it takes.
+	StrategyWait
+	// StrategyToleranceThenError waits like StrategyWait when the clock is at most Tolerance behind,
+	// and fails right away like StrategyError when it is further behind
+	StrategyToleranceThenError
+	// StrategyRecovery keeps generating from the last timestamp, borrowing the next milliseconds when the
+	// sequence overflows, so calls don't fail or block. Ids stay unique and increasing, but their timestamps run
+	// ahead of the clock until it catches up. A non-zero Tolerance fails once the clock is further behind.
+	StrategyRecovery
+)
+
+// ClockParams tunes a ClockStrategy, each strategy only reads the fields its doc mentions
+type ClockParams struct {
+	// how far the clock may move back behind its latest reading
+	Tolerance time.Duration
+	// how long to wait for the clock to catch up
+	MaxWait time.Duration
+}
+
+// ActiveTimeSource returns the time source the generator currently reads, which is the fallback
+// one after WithFallbackTimeSource has switched over
+func (S *SnowflakeSeqGenerator) ActiveTimeSource() TimeSource {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	return S.timeSource
+}
+
+// recordBackward counts a backward clock event and reports whether the generator switched to the
+// fallback time source, the caller must hold S.mu
+func (S *SnowflakeSeqGenerator) recordBackward() bool {
+	if S.fallbackTimeSource == nil || S.fallbackActive {
+		return false
+	}
+	S.backwardEvents++
+	if S.backwardEvents < S.fallbackTrigger {
+		return false
+	}
+	S.timeSource = S.fallbackTimeSource
+	S.fallbackActive = true
+	return true
+}
+
+// handleBackward applies the clock strategy to a reading now behind the last timestamp and returns the
+// millisecond to generate in, the caller must hold S.mu
+func (S *SnowflakeSeqGenerator) handleBackward(now int64) (int64, error) {
+	// from the latest reading, milliseconds borrowed by StrategyRecovery are no clock drift
+	behind := time.Duration(S.clockHigh-now) * time.Millisecond
+	switch S.clockStrategy {
+	case StrategyWait:
+		return S.waitClock(S.clockParams.MaxWait)
+	case StrategyToleranceThenError:
+		if behind <= S.clockParams.Tolerance {
+			return S.waitClock(S.clockParams.Tolerance)
+		}
+	case StrategyRecovery:
+		if S.clockParams.Tolerance == 0 || behind <= S.clockParams.Tolerance {
+			return S.timestamp, nil
+		}
+	}
+	return 0, fmt.Errorf("Clock moved backwards. Refusing to generate ID, last timestamp is %d, now is %d", S.timestamp, now)
+}
+
+// waitClock polls the time source until it reaches the last timestamp, giving up after maxWait unless it is zero
+func (S *SnowflakeSeqGenerator) waitClock(maxWait time.Duration) (int64, error) {
+	deadline := time.Now().Add(maxWait)
+	for {
+		now := S.readClock()
+		if now >= S.timestamp {
+			return now, nil
+		}
+		if maxWait > 0 && time.Now().After(deadline) {
+			return 0, fmt.Errorf("Clock moved backwards. Still behind after waiting %v, last timestamp is %d, now is %d", maxWait, S.timestamp, now)
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+// readClock reads the time source and keeps the latest reading in S.clockHigh, the caller must hold S.mu
+func (S *SnowflakeSeqGenerator) readClock() int64 {
+	now := S.timeSource.UnixMilli()
+	if now > S.clockHigh {
+		S.clockHigh = now
+	}
+	return now
+}
+
+// checkClock compares the time source to the reference of WithClockSanityCheck
+func (S *SnowflakeSeqGenerator) checkClock() error {
+	ref, err := S.clockReference()
+	if err != nil {
+		return fmt.Errorf("clock sanity check: %w", err)
+	}
+	skew := time.Duration(S.timeSource.UnixMilli()-ref.UnixMilli()) * time.Millisecond
+	if skew > S.clockMaxSkew || skew < -S.clockMaxSkew {
+		return fmt.Errorf("clock sanity check: local clock is %v off the reference, max skew is %v", skew, S.clockMaxSkew)
+	}
+	return nil
+}
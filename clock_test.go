@@ -0,0 +1,276 @@
+package uidgo_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+	"uidgo"
+)
+
+// manualClock is a TimeSource that only moves when told to
+type manualClock struct {
+	millis atomic.Int64
+}
+
+func newManualClock() *manualClock {
+	c := new(manualClock)
+	c.millis.Store(time.Now().UnixMilli())
+	return c
+}
+
+func (c *manualClock) UnixMilli() int64 {
+	return c.millis.Load()
+}
+
+func (c *manualClock) Add(d time.Duration) {
+	c.millis.Add(d.Milliseconds())
+}
+
+// failingClock moves forward one millisecond per read, then jumps backwards after the given number of reads
+func failingClock(reads int) uidgo.TimeSource {
+	calls := 0
+	base := time.Now().UnixMilli()
+	return timeSourceFunc(func() int64 {
+		calls++
+		if calls > reads {
+			return base - 100
+		}
+		return base + int64(calls)
+	})
+}
+
+type timeSourceFunc func() int64
+
+func (f timeSourceFunc) UnixMilli() int64 {
+	return f()
+}
+
+func TestWithFallbackTimeSource(t *testing.T) {
+	primary, secondary := newManualClock(), newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1,
+		uidgo.WithTimeSource(primary), uidgo.WithFallbackTimeSource(secondary, 2))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	x, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	primary.Add(-10 * time.Millisecond)
+	secondary.Add(5 * time.Millisecond)
+	if _, err = generator.GenerateId2(); err == nil {
+		t.Error("the first backward event should fail")
+	}
+	if generator.ActiveTimeSource() != primary {
+		t.Error("switched to the fallback time source too early")
+	}
+	y, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if generator.ActiveTimeSource() != secondary {
+		t.Error("should switch to the fallback time source after 2 backward events")
+	}
+	if y <= x {
+		t.Errorf("y(%d) should be greater than x(%d)", y, x)
+	}
+}
+
+func TestWithClockSanityCheck(t *testing.T) {
+	skewed := func() (time.Time, error) {
+		return time.Now().Add(time.Hour), nil
+	}
+	if _, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithClockSanityCheck(skewed, time.Second)); err == nil {
+		t.Error("an hour of skew should fail the construction")
+	}
+	if _, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithClockSanityCheck(skewed, 2*time.Hour)); err != nil {
+		t.Errorf("skew within maxSkew should pass: %v", err)
+	}
+
+	failing := func() (time.Time, error) {
+		return time.Time{}, errors.New("ntp timeout")
+	}
+	if _, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithClockSanityCheck(failing, time.Second)); err == nil {
+		t.Error("a failing reference should fail the construction")
+	}
+}
+
+// newBackwardGenerator generates one id with strategy, then moves the clock back by d
+func newBackwardGenerator(t *testing.T, d time.Duration, strategy uidgo.ClockStrategy, params uidgo.ClockParams) (*uidgo.SnowflakeSeqGenerator, *manualClock, uint64) {
+	clock := newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1,
+		uidgo.WithTimeSource(clock), uidgo.WithClockStrategy(strategy, params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := generator.GenerateId2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(-d)
+	return generator, clock, x
+}
+
+func TestWithClockStrategy_Error(t *testing.T) {
+	generator, clock, x := newBackwardGenerator(t, 5*time.Millisecond, uidgo.StrategyError, uidgo.ClockParams{})
+	if _, err := generator.GenerateId2(); err == nil {
+		t.Error("StrategyError should fail while the clock is behind")
+	}
+
+	clock.Add(6 * time.Millisecond)
+	y, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if y <= x {
+		t.Errorf("y(%d) should be greater than x(%d)", y, x)
+	}
+}
+
+func TestWithClockStrategy_Wait(t *testing.T) {
+	params := uidgo.ClockParams{MaxWait: 20 * time.Millisecond}
+	generator, clock, x := newBackwardGenerator(t, 5*time.Millisecond, uidgo.StrategyWait, params)
+	start := time.Now()
+	if _, err := generator.GenerateId2(); err == nil {
+		t.Error("StrategyWait should fail when the clock is stuck for longer than MaxWait")
+	}
+	if waited := time.Since(start); waited < params.MaxWait {
+		t.Errorf("gave up after %v, should wait %v", waited, params.MaxWait)
+	}
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		clock.Add(5 * time.Millisecond)
+	}()
+	y, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if y <= x || generator.Decode(y).Timestamp > clock.UnixMilli() {
+		t.Errorf("y(%d) should be after x(%d) and not ahead of the clock", y, x)
+	}
+}
+
+func TestWithClockStrategy_ToleranceThenError(t *testing.T) {
+	params := uidgo.ClockParams{Tolerance: 50 * time.Millisecond}
+	generator, clock, _ := newBackwardGenerator(t, time.Second, uidgo.StrategyToleranceThenError, params)
+	start := time.Now()
+	if _, err := generator.GenerateId2(); err == nil {
+		t.Error("a clock a second behind should fail")
+	}
+	if waited := time.Since(start); waited >= params.Tolerance {
+		t.Errorf("should fail right away past the tolerance, took %v", waited)
+	}
+
+	// now only 3ms behind
+	clock.Add(time.Second - 3*time.Millisecond)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		clock.Add(3 * time.Millisecond)
+	}()
+	if _, err := generator.GenerateId2(); err != nil {
+		t.Errorf("a clock within the tolerance should be waited for, got %v", err)
+	}
+
+	if _, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithClockStrategy(uidgo.StrategyToleranceThenError, uidgo.ClockParams{})); err == nil {
+		t.Error("StrategyToleranceThenError without a tolerance should be rejected")
+	}
+}
+
+func TestWithClockStrategy_Recovery(t *testing.T) {
+	generator, clock, x := newBackwardGenerator(t, time.Second, uidgo.StrategyRecovery, uidgo.ClockParams{})
+	last := generator.Decode(x).Timestamp
+
+	// more ids than fit in a millisecond, the clock never moves
+	ids, err := generator.GenerateIds(10000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	prev := x
+	for i, id := range ids {
+		if id <= prev {
+			t.Errorf("ids[%d](%d) should be greater than %d", i, id, prev)
+			return
+		}
+		prev = id
+	}
+	if ts := generator.Decode(prev).Timestamp; ts <= last || ts > last+3 {
+		t.Errorf("the last id is from %d, should borrow a few milliseconds after %d", ts, last)
+	}
+	if clock.UnixMilli() >= last {
+		t.Error("the clock should still be behind")
+	}
+
+	bounded, clock, _ := newBackwardGenerator(t, time.Second, uidgo.StrategyRecovery, uidgo.ClockParams{Tolerance: 10 * time.Millisecond})
+	if _, err = bounded.GenerateId2(); err == nil {
+		t.Error("a clock past the tolerance should fail")
+	}
+	clock.Add(995 * time.Millisecond)
+	if _, err = bounded.GenerateId2(); err != nil {
+		t.Errorf("a clock within the tolerance should recover, got %v", err)
+	}
+}
+
+func TestWithClockStrategy_RecoveryWithFallback(t *testing.T) {
+	primary, secondary := newManualClock(), newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(primary),
+		uidgo.WithFallbackTimeSource(secondary, 3), uidgo.WithClockStrategy(uidgo.StrategyRecovery, uidgo.ClockParams{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// the burst borrows milliseconds ahead of a healthy primary clock
+	if _, err = generator.GenerateIds(5000); err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 5; i++ {
+		if _, err = generator.GenerateId2(); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if generator.ActiveTimeSource() != primary {
+		t.Error("borrowed milliseconds should not count as backward events")
+	}
+
+	// the primary clock really moves backwards
+	primary.Add(-time.Second)
+	for i := 0; i < 3; i++ {
+		if _, err = generator.GenerateId2(); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if generator.ActiveTimeSource() != secondary {
+		t.Error("should switch to the fallback time source after 3 backward events")
+	}
+}
+
+func TestWithClockStrategy_RecoveryBurst(t *testing.T) {
+	clock := newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock),
+		uidgo.WithClockStrategy(uidgo.StrategyRecovery, uidgo.ClockParams{Tolerance: 10 * time.Millisecond}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// each burst borrows 20ms, twice the tolerance, from a clock that only moves forward
+	for i := 0; i < 3; i++ {
+		if _, err = generator.GenerateIds(4096 * 20); err != nil {
+			t.Errorf("a burst ahead of a healthy clock should not fail: %v", err)
+			return
+		}
+		clock.Add(time.Millisecond)
+	}
+}
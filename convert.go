@@ -0,0 +1,86 @@
+package uidgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// IdsToStrings formats ids as decimal strings, for APIs whose clients can't hold a 64-bit integer
+func IdsToStrings(ids []uint64) []string {
+	ss := make([]string, len(ids))
+	for i, id := range ids {
+		ss[i] = strconv.FormatUint(id, 10)
+	}
+	return ss
+}
+
+// StringsToIds parses decimal strings back into ids, the error reports the index of the first bad string
+func StringsToIds(ss []string) ([]uint64, error) {
+	ids := make([]uint64, len(ss))
+	for i, s := range ss {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("string at index %d is not an id: %w", i, err)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// GenerateIdBytes generates an id as 8 big-endian bytes, for protobuf bytes fields and byte-ordered keys.
+// Comparing the bytes orders ids like comparing the numbers.
+func (S *SnowflakeSeqGenerator) GenerateIdBytes() ([8]byte, error) {
+	var b [8]byte
+	r, err := S.generateOne()
+	if err != nil {
+		return b, err
+	}
+	binary.BigEndian.PutUint64(b[:], uint64(r))
+	return b, nil
+}
+
+// IdFromBytes returns the id of bytes from GenerateIdBytes
+func IdFromBytes(b [8]byte) uint64 {
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// ToVarint encodes id as an unsigned varint of 1 to 10 bytes, ids close to the epoch take fewer bytes.
+// Unlike GenerateIdBytes, comparing the bytes does not order ids like comparing the numbers.
+func ToVarint(id uint64) []byte {
+	return binary.AppendUvarint(nil, id)
+}
+
+// FromVarint decodes an id from the start of b, also returning how many bytes it took
+func FromVarint(b []byte) (uint64, int, error) {
+	id, n := binary.Uvarint(b)
+	if n == 0 {
+		return 0, 0, fmt.Errorf("varint is truncated after %d bytes", len(b))
+	}
+	if n < 0 {
+		return 0, 0, fmt.Errorf("varint overflows 64 bits after %d bytes", -n)
+	}
+	return id, n, nil
+}
+
+// FromLegacySequence maps an auto-increment id into the snowflake space for a migration: the legacy id fills the
+// node and sequence bits under the timestamp of createdAt, so records keep their order, also within a millisecond.
+// The mapped ids look like ids of any node, e.g. legacy 5 decodes to node dc0-w0 sequence 5. They can't collide
+// with generated ids only because they are from before cutover, the time the first generator started, so
+// createdAt at or after cutover is rejected.
+func (S *SnowflakeSeqGenerator) FromLegacySequence(legacy int64, createdAt, cutover time.Time) (uint64, error) {
+	shift := S.layout.timestampShift()
+	if legacy < 0 || legacy >= 1<<shift {
+		return 0, fmt.Errorf("legacy id %d doesn't fit the %d low bits", legacy, shift)
+	}
+	if !createdAt.Before(cutover) {
+		return 0, fmt.Errorf("createdAt(%v) should be before the cutover(%v)", createdAt, cutover)
+	}
+	millis, e := createdAt.UnixMilli(), S.epoch.Load()
+	tmp := millis - e
+	if tmp < 0 || tmp > S.layout.maxTimestamp() {
+		return 0, fmt.Errorf("createdAt(%d) should be within %d bits after the epoch(%d)", millis, S.layout.TimestampBits, e)
+	}
+	return uint64(tmp<<shift | legacy), nil
+}
@@ -0,0 +1,156 @@
+package uidgo_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"uidgo"
+)
+
+func TestIdsToStrings(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ids := make([]uint64, 10)
+	for i := range ids {
+		if ids[i], err = generator.GenerateId2(); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	got, err := uidgo.StringsToIds(uidgo.IdsToStrings(ids))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := range ids {
+		if got[i] != ids[i] {
+			t.Errorf("ids[%d] is %d after the round trip, want %d", i, got[i], ids[i])
+		}
+	}
+
+	if _, err = uidgo.StringsToIds([]string{"1", "2", "x3"}); err == nil || !strings.Contains(err.Error(), "index 2") {
+		t.Errorf("error(%v) should report index 2", err)
+	}
+}
+
+func TestSnowflakeSeqGenerator_GenerateIdBytes(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var x [8]byte
+	for i := 0; i < 5000; i++ {
+		y, err := generator.GenerateIdBytes()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if i > 0 {
+			if bytes.Compare(y[:], x[:]) <= 0 {
+				t.Errorf("y(%x) should sort after x(%x)", y, x)
+			}
+			if uidgo.IdFromBytes(y) <= uidgo.IdFromBytes(x) {
+				t.Errorf("y(%d) should be greater than x(%d)", uidgo.IdFromBytes(y), uidgo.IdFromBytes(x))
+			}
+		}
+		x = y
+	}
+	id := uidgo.IdFromBytes(x)
+	if c := generator.Decode(id); c.DataCenterId != 1 || c.WorkerId != 1 {
+		t.Errorf("id(%d) decodes to the wrong node(%+v)", id, c)
+	}
+}
+
+func TestToVarint(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	id, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, tc := range []struct {
+		id   uint64
+		size int
+	}{
+		{0, 1},
+		{127, 1},
+		{128, 2},
+		{1<<63 - 1, 9},
+		{1<<64 - 1, 10},
+	} {
+		b := uidgo.ToVarint(tc.id)
+		if len(b) != tc.size {
+			t.Errorf("id(%d) takes %d bytes, want %d", tc.id, len(b), tc.size)
+		}
+		got, n, err := uidgo.FromVarint(append(b, 0xff))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if got != tc.id || n != len(b) {
+			t.Errorf("round trip of %d gives %d in %d bytes, want %d bytes", tc.id, got, n, len(b))
+		}
+	}
+
+	b := uidgo.ToVarint(id)
+	if got, n, err := uidgo.FromVarint(b); err != nil || got != id || n != len(b) {
+		t.Errorf("round trip of %d gives %d in %d bytes(%v)", id, got, n, err)
+	}
+	if _, _, err = uidgo.FromVarint(b[:2]); err == nil {
+		t.Error("a truncated varint should fail")
+	}
+}
+
+func TestSnowflakeSeqGenerator_FromLegacySequence(t *testing.T) {
+	epoch := time.Date(2020, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	createdAt := time.Date(2022, time.May, 01, 00, 00, 00, 00, time.UTC)
+	cutover := time.Date(2023, time.January, 01, 00, 00, 00, 00, time.UTC)
+	var x uint64
+	for _, legacy := range []int64{1, 2, 1<<22 - 1} {
+		// same millisecond, ordered by the legacy id
+		y, err := generator.FromLegacySequence(legacy, createdAt, cutover)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if c := generator.Decode(y); c.Timestamp != createdAt.UnixMilli() {
+			t.Errorf("y(%d) decodes to %d, want createdAt", y, c.Timestamp)
+		}
+		if y <= x {
+			t.Errorf("y(%d) should be greater than x(%d)", y, x)
+		}
+		x = y
+	}
+	later, err := generator.FromLegacySequence(0, createdAt.Add(time.Millisecond), cutover)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if later <= x {
+		t.Errorf("a later record(%d) should sort after x(%d)", later, x)
+	}
+
+	if _, err = generator.FromLegacySequence(1<<22, createdAt, cutover); err == nil {
+		t.Error("legacy id 2^22 doesn't fit the 22 low bits")
+	}
+	if _, err = generator.FromLegacySequence(1, time.UnixMilli(epoch-1), cutover); err == nil {
+		t.Error("createdAt before the epoch should be rejected")
+	}
+	if _, err = generator.FromLegacySequence(1, cutover, cutover); err == nil {
+		t.Error("createdAt at the cutover should be rejected")
+	}
+}
@@ -0,0 +1,206 @@
+package uidgo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how far VerifyAgainst lets the sample time be from the time decoded from the sample id
+const verifyTolerance = time.Second
+
+var (
+	// ErrReservedBitSet means a bit above the fields of the layout is set, the sign bit included
+	ErrReservedBitSet = errors.New("uidgo: reserved bit set")
+	// ErrTimestampInFuture means the timestamp of the id is after the current time
+	ErrTimestampInFuture = errors.New("uidgo: timestamp in future")
+	// ErrFieldOutOfRange means a field holds a value the generator never produces, like a sequence over WithMaxSequence
+	ErrFieldOutOfRange = errors.New("uidgo: field out of range")
+)
+
+// Components holds the fields packed into an id
+type Components struct {
+	// unix milliseconds, the epoch is already added back
+	Timestamp    int64
+	DataCenterId int64
+	WorkerId     int64
+	Sequence     int64
+}
+
+// Decode splits id into its fields
+func (S *SnowflakeSeqGenerator) Decode(id uint64) Components {
+	tmp, dataCenterId, workerId, sequence := S.layout.unpack(id)
+	return Components{
+		Timestamp:    tmp + S.epoch.Load(),
+		DataCenterId: dataCenterId,
+		WorkerId:     workerId,
+		Sequence:     sequence,
+	}
+}
+
+// StrictDecode splits id into its fields like Decode, but rejects ids this generator could not have produced.
+// The error wraps one of ErrReservedBitSet, ErrTimestampInFuture and ErrFieldOutOfRange. There is no before-epoch
+// check: the timestamp is an unsigned offset from the epoch, so no id decodes to a time before it.
+func (S *SnowflakeSeqGenerator) StrictDecode(id uint64) (Components, error) {
+	width := S.layout.TimestampBits + S.layout.timestampShift()
+	// width is at most 63, so the sign bit is always reserved
+	if id>>width != 0 {
+		return Components{}, fmt.Errorf("%w: id %d has bits set above bit %d", ErrReservedBitSet, id, width-1)
+	}
+
+	c := S.Decode(id)
+	if now := S.ActiveTimeSource().UnixMilli(); c.Timestamp > now {
+		return c, fmt.Errorf("%w: id %d has timestamp %d, now is %d", ErrTimestampInFuture, id, c.Timestamp, now)
+	}
+	// nonce bits and the counter below them are both under the max sequence
+	if c.Sequence > S.maxSequence {
+		return c, fmt.Errorf("%w: id %d has sequence %d, max is %d", ErrFieldOutOfRange, id, c.Sequence, S.maxSequence)
+	}
+	return c, nil
+}
+
+// unixMilli returns the unix millisecond timestamp embedded in id
+func (S *SnowflakeSeqGenerator) unixMilli(id uint64) int64 {
+	return int64(id>>S.layout.timestampShift()) + S.epoch.Load()
+}
+
+// PartitionByTime splits ids around pivot, keeping the input order in both halves.
+// An id minted in the pivot millisecond or later is placed in after, so before holds
+// only the ids strictly older than pivot.
+func (S *SnowflakeSeqGenerator) PartitionByTime(ids []uint64, pivot time.Time) (before, after []uint64) {
+	p := pivot.UnixMilli()
+	for _, id := range ids {
+		if S.unixMilli(id) < p {
+			before = append(before, id)
+		} else {
+			after = append(after, id)
+		}
+	}
+	return before, after
+}
+
+// EpochFromSample returns the epoch in unix milliseconds of the generator that minted id at realTime,
+// for ids of DefaultLayout
+func EpochFromSample(id uint64, realTime time.Time) int64 {
+	return DefaultLayout.EpochFromSample(id, realTime)
+}
+
+// Elapsed returns the time from the timestamp of a to the timestamp of b, negative if b is older than a
+func (S *SnowflakeSeqGenerator) Elapsed(a, b uint64) time.Duration {
+	return time.Duration(S.unixMilli(b)-S.unixMilli(a)) * time.Millisecond
+}
+
+// Regressions returns the indexes of the ids that are not greater than the id before them
+func Regressions(ids []uint64) []int {
+	var r []int
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+// VerifyAgainst checks the epoch and layout of the generator against sampleId, an id minted by the existing cluster
+// at sampleTime, so a misconfigured node is caught before it issues ids. The time decoded from sampleId may be
+// up to a second away from sampleTime.
+func (S *SnowflakeSeqGenerator) VerifyAgainst(sampleId uint64, sampleTime time.Time) error {
+	if width := S.layout.TimestampBits + S.layout.timestampShift(); sampleId>>width != 0 {
+		return fmt.Errorf("sample id %d is wider than the %d bits of the layout", sampleId, width)
+	}
+	inferred := S.layout.EpochFromSample(sampleId, sampleTime)
+	e := S.epoch.Load()
+	if d := time.Duration(inferred-e) * time.Millisecond; d > verifyTolerance || d < -verifyTolerance {
+		return fmt.Errorf("sample id %d puts the epoch %v away from epoch(%d), the epoch or layout doesn't match the cluster", sampleId, d, e)
+	}
+	return nil
+}
+
+// SequencePosition returns the number of ids the node of id issued before it in the same millisecond, together
+// with how many ids fit a millisecond. Nonce bits and the sequence stride are undone, and the capacity follows
+// WithMaxSequence and WithNonceBits.
+func (S *SnowflakeSeqGenerator) SequencePosition(id uint64) (used int64, capacity int64) {
+	capacity = S.maxSequence>>S.nonceBits + 1
+	// the counter sits under the nonce bits
+	used = int64(id) & (1<<(bits.Len64(uint64(S.maxSequence))-S.nonceBits) - 1)
+	if S.stride > 1 {
+		// the stride is coprime with capacity, so it has an inverse
+		used = used * modInverse(S.stride, capacity) % capacity
+	}
+	return used, capacity
+}
+
+// modInverse returns x with a*x = 1 modulo m, a and m must be coprime
+func modInverse(a, m int64) int64 {
+	x, nx := int64(0), int64(1)
+	r, nr := m, a%m
+	for nr != 0 {
+		q := r / nr
+		x, nx = nx, x-q*nx
+		r, nr = nr, r-q*nr
+	}
+	if x < 0 {
+		x += m
+	}
+	return x
+}
+
+// AuditEpochEra returns the indexes of the ids whose time falls outside [expectedStart, expectedEnd), which flags
+// ids minted under another epoch or layout
+func (S *SnowflakeSeqGenerator) AuditEpochEra(ids []uint64, expectedStart, expectedEnd time.Time) []int {
+	start, end := expectedStart.UnixMilli(), expectedEnd.UnixMilli()
+	var r []int
+	for i, id := range ids {
+		if m := S.unixMilli(id); m < start || m >= end {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+// DecodeStream reads newline-delimited decimal ids from r and calls fn with each one decoded, stopping at the first
+// fn error. Blank lines are skipped, a malformed line fails with its line number.
+func (S *SnowflakeSeqGenerator) DecodeStream(r io.Reader, fn func(Components) error) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid id %q: %w", line, text, err)
+		}
+		if err = fn(S.Decode(id)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// how far past now SuggestEpoch wants the timestamp range to reach before it stops rounding to the year
+const suggestEpochFuture = 20 * 365 * 24 * time.Hour
+
+// SuggestEpoch returns an epoch for a new deployment whose oldest data is at earliest: the start of the UTC year
+// of earliest, like the default epoch, so historical ids fit. When the timestamp bits from there don't reach 20
+// years past now, it rounds to the start of the UTC day instead to keep as much future range as possible, and
+// fails when even that falls short, as earliest is too old for the layout. Now is read from the time source.
+func (S *SnowflakeSeqGenerator) SuggestEpoch(earliest time.Time) (int64, error) {
+	earliest = earliest.UTC()
+	want := S.ActiveTimeSource().UnixMilli() + suggestEpochFuture.Milliseconds()
+	epoch := time.Date(earliest.Year(), time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	if epoch+S.layout.maxTimestamp() >= want {
+		return epoch, nil
+	}
+	epoch = time.Date(earliest.Year(), earliest.Month(), earliest.Day(), 00, 00, 00, 00, time.UTC).UnixMilli()
+	if end := epoch + S.layout.maxTimestamp(); end < want {
+		return 0, fmt.Errorf("%d timestamp bits from %v end at %v, less than 20 years from now",
+			S.layout.TimestampBits, time.UnixMilli(epoch).UTC(), time.UnixMilli(end).UTC())
+	}
+	return epoch, nil
+}
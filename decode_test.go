@@ -0,0 +1,358 @@
+package uidgo_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"uidgo"
+)
+
+// one millisecond in the timestamp field of the default layout
+const millisecondTick = 1 << 22
+
+func TestSnowflakeSeqGenerator_PartitionByTime(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	start := time.Now().UnixMilli()
+	id, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	end := time.Now().UnixMilli()
+
+	ids := []uint64{id - millisecondTick, id, id + millisecondTick}
+	found := 0
+	for m := start; m <= end; m++ {
+		before, after := generator.PartitionByTime(ids, time.UnixMilli(m))
+		if len(before)+len(after) != len(ids) {
+			t.Errorf("before(%v) & after(%v) lost ids", before, after)
+		}
+		if len(before) != 1 {
+			continue
+		}
+		// pivot is the millisecond of id, which belongs to after
+		found++
+		if before[0] != ids[0] || after[0] != ids[1] || after[1] != ids[2] {
+			t.Errorf("before(%v) & after(%v) split at the wrong id", before, after)
+		}
+	}
+	if found != 1 {
+		t.Errorf("pivot millisecond found %d times", found)
+	}
+}
+
+func TestEpochFromSample(t *testing.T) {
+	epoch := time.Date(2020, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	id, c, err := generator.GenerateIdWithComponents()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if e := uidgo.EpochFromSample(id, time.UnixMilli(c.Timestamp)); e != epoch {
+		t.Errorf("epoch from sample(%d) & epoch(%d) are different", e, epoch)
+	}
+}
+
+func TestSnowflakeSeqGenerator_Elapsed(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	a, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b := a + 250*millisecondTick + 3
+	if d := generator.Elapsed(a, b); d != 250*time.Millisecond {
+		t.Errorf("elapsed(%v) should be 250ms", d)
+	}
+	if d := generator.Elapsed(b, a); d != -250*time.Millisecond {
+		t.Errorf("elapsed(%v) should be -250ms", d)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	ids := []uint64{10, 20, 15, 30, 30, 40, 5}
+	got := uidgo.Regressions(ids)
+	want := []int{2, 4, 6}
+	if len(got) != len(want) {
+		t.Errorf("regressions(%v) should be %v", got, want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("regressions(%v) should be %v", got, want)
+		}
+	}
+	if got = uidgo.Regressions([]uint64{1, 2, 3}); len(got) != 0 {
+		t.Errorf("regressions(%v) should be empty", got)
+	}
+}
+
+func TestSnowflakeSeqGenerator_StrictDecode(t *testing.T) {
+	clock := newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1,
+		uidgo.WithTimeSource(clock), uidgo.WithMaxSequence(100), uidgo.WithLayout(uidgo.Layout{40, 5, 5, 12}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	id, c, err := generator.GenerateIdWithComponents()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d, err := generator.StrictDecode(id); err != nil || d != c {
+		t.Errorf("decoded(%+v, %v), want components(%+v)", d, err, c)
+	}
+
+	// bit 62 is above the 62 bits of the layout, one millisecond is 1<<22
+	for _, tc := range []struct {
+		id   uint64
+		want error
+	}{
+		{id | 1<<63, uidgo.ErrReservedBitSet},
+		{id | 1<<62, uidgo.ErrReservedBitSet},
+		{id + 1000<<22, uidgo.ErrTimestampInFuture},
+		{id | 101, uidgo.ErrFieldOutOfRange},
+	} {
+		if _, err = generator.StrictDecode(tc.id); !errors.Is(err, tc.want) {
+			t.Errorf("id(%d): error(%v) should be %v", tc.id, err, tc.want)
+		}
+	}
+
+	// the sign bit is the reserved bit of the 63-bit default layout
+	generator, err = uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if id, err = generator.GenerateId2(); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = generator.StrictDecode(id | 1<<63); !errors.Is(err, uidgo.ErrReservedBitSet) {
+		t.Errorf("error(%v) should be ErrReservedBitSet", err)
+	}
+}
+
+func TestSnowflakeSeqGenerator_VerifyAgainst(t *testing.T) {
+	epoch := time.Date(2020, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	cluster, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sampleTime := time.Now()
+	sampleId, err := cluster.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	node, err := uidgo.NewSnowflakeSeqGenerator(1, 2, uidgo.WithEpoch(epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = node.VerifyAgainst(sampleId, sampleTime); err != nil {
+		t.Errorf("a node with the cluster config should pass: %v", err)
+	}
+
+	for _, opts := range [][]uidgo.Option{
+		{},
+		{uidgo.WithEpoch(epoch), uidgo.WithLayout(uidgo.Layout{43, 4, 4, 12})},
+	} {
+		node, err = uidgo.NewSnowflakeSeqGenerator(1, 2, opts...)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = node.VerifyAgainst(sampleId, sampleTime); err == nil {
+			t.Error("a node with another epoch or layout should fail")
+		}
+	}
+}
+
+func TestSnowflakeSeqGenerator_SequencePosition(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithLayout(uidgo.LayoutManyNodes))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, seq := range []int64{0, 7, 1023} {
+		id, err := generator.GenerateIdExact(generator.Epoch()+1000, seq)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		used, capacity := generator.SequencePosition(id)
+		if used != seq || capacity != 1024 {
+			t.Errorf("position(%d/%d), want %d/1024", used, capacity, seq)
+		}
+	}
+	for _, tc := range []struct {
+		opt      uidgo.Option
+		capacity int64
+	}{
+		{uidgo.WithNonceBits(4), 256},
+		{uidgo.WithSequenceStride(5), 4096},
+		{uidgo.WithMaxSequence(99), 100},
+	} {
+		generator, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(newManualClock()), tc.opt)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// the clock never moves, so the ids count up from the start of one millisecond
+		for i := int64(0); i < 10; i++ {
+			id, err := generator.GenerateId2()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if used, capacity := generator.SequencePosition(id); used != i || capacity != tc.capacity {
+				t.Errorf("position(%d/%d), want %d/%d", used, capacity, i, tc.capacity)
+			}
+		}
+	}
+}
+
+func TestSnowflakeSeqGenerator_AuditEpochEra(t *testing.T) {
+	epoch := time.Date(2020, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	start := time.Date(2024, time.January, 01, 00, 00, 00, 00, time.UTC)
+	end := time.Date(2025, time.January, 01, 00, 00, 00, 00, time.UTC)
+	var ids []uint64
+	for _, at := range []time.Time{
+		start,
+		start.AddDate(0, 6, 0),
+		start.Add(-time.Millisecond),
+		end.Add(-time.Millisecond),
+		end,
+		time.Date(2021, time.March, 01, 00, 00, 00, 00, time.UTC),
+	} {
+		id, err := generator.GenerateIdExact(at.UnixMilli(), 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	got := generator.AuditEpochEra(ids, start, end)
+	want := []int{2, 4, 5}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("out of era indexes are %v, want %v", got, want)
+	}
+}
+
+func TestSnowflakeSeqGenerator_DecodeStream(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ids, err := generator.GenerateIds(3)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	lines := uidgo.IdsToStrings(ids)
+
+	var got []uidgo.Components
+	collect := func(c uidgo.Components) error {
+		got = append(got, c)
+		return nil
+	}
+	if err = generator.DecodeStream(strings.NewReader(strings.Join(lines, "\n")+"\n\n"), collect); err != nil {
+		t.Error(err)
+	}
+	if len(got) != len(ids) {
+		t.Errorf("decoded %d ids, want %d", len(got), len(ids))
+	}
+	for i := range got {
+		if got[i] != generator.Decode(ids[i]) {
+			t.Errorf("line %d decoded to %+v", i+1, got[i])
+		}
+	}
+
+	got = nil
+	malformed := lines[0] + "\n" + lines[1] + "\nnot-an-id\n" + lines[2]
+	err = generator.DecodeStream(strings.NewReader(malformed), collect)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("error(%v) should report line 3", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("decoded %d ids before the malformed line, want 2", len(got))
+	}
+
+	stop := errors.New("stop")
+	err = generator.DecodeStream(strings.NewReader(malformed), func(uidgo.Components) error { return stop })
+	if err != stop {
+		t.Errorf("error(%v) should be the fn error", err)
+	}
+}
+
+func TestSnowflakeSeqGenerator_SuggestEpoch(t *testing.T) {
+	clock := newManualClock()
+	clock.millis.Store(time.Date(2026, time.June, 15, 00, 00, 00, 00, time.UTC).UnixMilli())
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	earliest := time.Date(2019, time.June, 15, 12, 30, 00, 00, time.UTC)
+	epoch, err := generator.SuggestEpoch(earliest)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want := time.Date(2019, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli(); epoch != want {
+		t.Errorf("epoch(%v) should be the start of 2019", time.UnixMilli(epoch).UTC())
+	}
+	generator, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = generator.FromLegacySequence(0, earliest, time.Now()); err != nil {
+		t.Errorf("the earliest data should fit the suggested epoch: %v", err)
+	}
+
+	// 40 bits last about 34.8 years, from 2011 they end months short of 2046
+	generator, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock), uidgo.WithLayout(uidgo.Layout{40, 5, 5, 12}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	earliest = time.Date(2011, time.October, 01, 8, 00, 00, 00, time.UTC)
+	epoch, err = generator.SuggestEpoch(earliest)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want := time.Date(2011, time.October, 01, 00, 00, 00, 00, time.UTC).UnixMilli(); epoch != want {
+		t.Errorf("epoch(%v) should be the start of the day of %v", time.UnixMilli(epoch).UTC(), earliest)
+	}
+
+	// from 2005 even the start of the day ends in 2039
+	if _, err = generator.SuggestEpoch(time.Date(2005, time.March, 01, 00, 00, 00, 00, time.UTC)); err == nil {
+		t.Error("a range ending before 20 years from now should fail")
+	}
+}
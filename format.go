@@ -0,0 +1,84 @@
+package uidgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format is a way to store an id
+type Format int
+
+const (
+	// FormatBinary is the 8-byte big-endian integer
+	FormatBinary Format = iota
+	// FormatDecimal is the decimal string
+	FormatDecimal
+	// FormatHex is the hexadecimal string
+	FormatHex
+	// FormatBase62 is the 0-9A-Za-z string
+	FormatBase62
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatBinary:
+		return "binary"
+	case FormatDecimal:
+		return "decimal"
+	case FormatHex:
+		return "hex"
+	case FormatBase62:
+		return "base62"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// EstimateBytes returns roughly how many bytes count ids take in encoding, without any per-row overhead.
+// String sizes are the widest id the layout can produce, so real data of a young epoch takes a little less.
+func (S *SnowflakeSeqGenerator) EstimateBytes(count int, encoding Format) int {
+	var width int
+	switch encoding {
+	case FormatDecimal:
+		width = digits(S.layout.maxId(), 10)
+	case FormatHex:
+		width = digits(S.layout.maxId(), 16)
+	case FormatBase62:
+		width = digits(S.layout.maxId(), 62)
+	default:
+		width = 8
+	}
+	return count * width
+}
+
+// digits returns the number of digits of n in base
+func digits(n uint64, base uint64) int {
+	d := 1
+	for n >= base {
+		n /= base
+		d++
+	}
+	return d
+}
+
+// BitString returns the 64 bits of id grouped by field, from the sign bit (and any bits the layout leaves
+// unused) to the timestamp, dataCenterId, workerId and sequence, like 0|00...101|00011|00111|000000000001
+func (S *SnowflakeSeqGenerator) BitString(id uint64) string {
+	l := S.layout
+	bits := strconv.FormatUint(id, 2)
+	bits = strings.Repeat("0", 64-len(bits)) + bits
+
+	var b strings.Builder
+	pos := 0
+	for _, n := range []int{64 - l.TimestampBits - l.timestampShift(), l.TimestampBits, l.DataCenterIdBits, l.WorkerIdBits, l.SequenceBits} {
+		if n == 0 {
+			continue
+		}
+		if pos > 0 {
+			b.WriteByte('|')
+		}
+		b.WriteString(bits[pos : pos+n])
+		pos += n
+	}
+	return b.String()
+}
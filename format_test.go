@@ -0,0 +1,70 @@
+package uidgo_test
+
+import (
+	"strings"
+	"testing"
+	"uidgo"
+)
+
+func TestSnowflakeSeqGenerator_EstimateBytes(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// the widest id of the default layout is 2^63-1 = 9223372036854775807
+	for _, tc := range []struct {
+		encoding uidgo.Format
+		want     int
+	}{
+		{uidgo.FormatBinary, 8000},
+		{uidgo.FormatDecimal, 19000},
+		{uidgo.FormatHex, 16000},
+		{uidgo.FormatBase62, 11000},
+	} {
+		if got := generator.EstimateBytes(1000, tc.encoding); got != tc.want {
+			t.Errorf("%v: estimated %d bytes, want %d", tc.encoding, got, tc.want)
+		}
+	}
+
+	generator, err = uidgo.NewSnowflakeSeqGenerator(0, 0, uidgo.WithLayout(uidgo.Layout{41, 0, 0, 12}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// 2^53-1 = 9007199254740991
+	if got := generator.EstimateBytes(1, uidgo.FormatDecimal); got != 16 {
+		t.Errorf("estimated %d bytes, want 16", got)
+	}
+}
+
+func TestSnowflakeSeqGenerator_BitString(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(3, 7)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	id, err := generator.GenerateIdExact(generator.Epoch()+5, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := "0|" + strings.Repeat("0", 38) + "101|00011|00111|000000000001"
+	if s := generator.BitString(id); s != want {
+		t.Errorf("bit string is %s, want %s", s, want)
+	}
+
+	l := uidgo.Layout{40, 0, 10, 12}
+	generator, err = uidgo.NewSnowflakeSeqGenerator(0, 7, uidgo.WithLayout(l))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	groups := strings.Split(generator.BitString(id), "|")
+	for i, n := range []int{2, l.TimestampBits, l.WorkerIdBits, l.SequenceBits} {
+		if len(groups) != 4 || len(groups[i]) != n {
+			t.Errorf("groups(%v) should be 2/40/10/12 bits long", groups)
+			break
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package uidgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// most ids a single request to Handler can ask for
+const handlerMaxCount = 1000
+
+// Handler serves ids over HTTP: GET returns {"id":"..."}, or {"ids":["...",...]} with ?count=N for up to 1000 ids.
+// Ids are strings so JavaScript clients don't lose precision. A failed generation answers 503.
+func (S *SnowflakeSeqGenerator) Handler() http.Handler {
+	return http.HandlerFunc(S.serveHTTP)
+}
+
+func (S *SnowflakeSeqGenerator) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body any
+	if s := req.URL.Query().Get("count"); s == "" {
+		r, err := S.generateOne()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		body = struct {
+			Id ID `json:"id"`
+		}{ID(r)}
+	} else {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > handlerMaxCount {
+			http.Error(w, "count must be between 1 and "+strconv.Itoa(handlerMaxCount), http.StatusBadRequest)
+			return
+		}
+		ids, err := S.appendIds(make([]uint64, 0, n), n)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		for _, id := range ids {
+			S.notify(id)
+		}
+		strs := IdsToStrings(ids)
+		body = struct {
+			Ids []string `json:"ids"`
+		}{strs}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,85 @@
+package uidgo_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"uidgo"
+)
+
+func TestSnowflakeSeqGenerator_Handler(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	server := httptest.NewServer(generator.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var single struct {
+		Id uidgo.ID `json:"id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&single)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content type is %q", ct)
+	}
+	if c := generator.Decode(uint64(single.Id)); c.DataCenterId != 1 || c.WorkerId != 1 {
+		t.Errorf("id(%d) decodes to the wrong node(%+v)", single.Id, c)
+	}
+
+	resp, err = http.Get(server.URL + "?count=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch struct {
+		Ids []string `json:"ids"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&batch)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := uidgo.StringsToIds(batch.Ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 5 || len(uidgo.Regressions(ids)) != 0 || ids[0] <= uint64(single.Id) {
+		t.Errorf("batch(%v) should be 5 increasing ids after %d", ids, single.Id)
+	}
+
+	for _, tc := range []struct {
+		method, query string
+		want          int
+	}{
+		{http.MethodGet, "?count=0", http.StatusBadRequest},
+		{http.MethodGet, "?count=1001", http.StatusBadRequest},
+		{http.MethodGet, "?count=x", http.StatusBadRequest},
+		{http.MethodPost, "", http.StatusMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		generator.Handler().ServeHTTP(rec, httptest.NewRequest(tc.method, "/"+tc.query, nil))
+		if rec.Code != tc.want {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.query, rec.Code, tc.want)
+		}
+	}
+
+	future, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(time.Now().Add(time.Hour).UnixMilli()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	future.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("a failed generation answered %d, want 503", rec.Code)
+	}
+}
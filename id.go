@@ -0,0 +1,34 @@
+package uidgo
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ID is a generated id that marshals to JSON as a decimal string, since JSON numbers lose precision
+// over 2^53 in most clients. Both strings and numbers are accepted when unmarshaling.
+type ID uint64
+
+func (id ID) String() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func (id ID) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, id.String()), nil
+}
+
+func (id *ID) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(s) > 0 && s[0] == '"' {
+		var err error
+		if s, err = strconv.Unquote(s); err != nil {
+			return fmt.Errorf("invalid id %s: %w", data, err)
+		}
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid id %s: %w", data, err)
+	}
+	*id = ID(v)
+	return nil
+}
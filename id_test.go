@@ -0,0 +1,35 @@
+package uidgo_test
+
+import (
+	"encoding/json"
+	"testing"
+	"uidgo"
+)
+
+func TestID_JSON(t *testing.T) {
+	id := uidgo.ID(1<<62 + 1)
+	data, err := json.Marshal(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(data) != `"4611686018427387905"` {
+		t.Errorf("marshaled to %s, want a string", data)
+	}
+	for _, s := range []string{`"4611686018427387905"`, `4611686018427387905`} {
+		var got uidgo.ID
+		if err = json.Unmarshal([]byte(s), &got); err != nil {
+			t.Error(err)
+			continue
+		}
+		if got != id {
+			t.Errorf("unmarshaled %s to %d, want %d", s, got, id)
+		}
+	}
+	for _, s := range []string{`"12`, `12"`, `""12""`, `"x"`, `""`} {
+		var got uidgo.ID
+		if err = got.UnmarshalJSON([]byte(s)); err == nil {
+			t.Errorf("%s should be rejected, got %d", s, got)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package uidgo
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// each power of two range is split in 2^latencySubBits buckets, so a bucket is at most 12.5% wide
+	latencySubBits = 3
+	latencySub     = 1 << latencySubBits
+	latencyBuckets = latencySub + (64-latencySubBits)*latencySub
+)
+
+// LatencyHistogram counts durations in log-linear buckets like an HDR histogram: a fixed 4KB of counters,
+// percentiles within 12.5%. It is safe for concurrent use and recording doesn't lock.
+type LatencyHistogram struct {
+	counts [latencyBuckets]atomic.Uint64
+}
+
+// Record counts one duration, negative ones count as zero
+func (h *LatencyHistogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[latencyBucket(uint64(d))].Add(1)
+}
+
+// Percentile returns the duration p percent of the recorded ones are at or under, rounded up to the top of its
+// bucket. p is clamped to 0..100, and it returns 0 when nothing was recorded.
+func (h *LatencyHistogram) Percentile(p float64) time.Duration {
+	counts := make([]uint64, latencyBuckets)
+	var total uint64
+	for i := range h.counts {
+		counts[i] = h.counts[i].Load()
+		total += counts[i]
+	}
+	if total == 0 {
+		return 0
+	}
+	p = math.Max(0, math.Min(100, p))
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, c := range counts {
+		seen += c
+		if seen >= rank {
+			return time.Duration(latencyBucketMax(i))
+		}
+	}
+	return time.Duration(latencyBucketMax(latencyBuckets - 1))
+}
+
+// latencyBucket returns the bucket of ns, exact under latencySub and log-linear above
+func latencyBucket(ns uint64) int {
+	if ns < latencySub {
+		return int(ns)
+	}
+	shift := bits.Len64(ns) - 1 - latencySubBits
+	return latencySub + shift*latencySub + int(ns>>shift)&(latencySub-1)
+}
+
+// latencyBucketMax returns the largest ns of bucket i
+func latencyBucketMax(i int) uint64 {
+	if i < latencySub {
+		return uint64(i)
+	}
+	shift := (i - latencySub) / latencySub
+	sub := uint64((i-latencySub)%latencySub) + latencySub
+	return (sub+1)<<shift - 1
+}
+
+// LatencyPercentile returns the p percentile of the time generate calls took, including the wait for the lock
+// and for the next millisecond on overflow. It returns 0 without WithLatencyTracking.
+func (S *SnowflakeSeqGenerator) LatencyPercentile(p float64) time.Duration {
+	if S.latency == nil {
+		return 0
+	}
+	return S.latency.Percentile(p)
+}
+
+// recordLatency counts the time since start of a generate call, the caller must not hold S.mu
+func (S *SnowflakeSeqGenerator) recordLatency(start time.Time) {
+	if S.latency != nil {
+		S.latency.Record(time.Since(start))
+	}
+}
@@ -0,0 +1,59 @@
+package uidgo_test
+
+import (
+	"testing"
+	"time"
+	"uidgo"
+)
+
+func TestLatencyHistogram_Percentile(t *testing.T) {
+	h := new(uidgo.LatencyHistogram)
+	if p := h.Percentile(50); p != 0 {
+		t.Errorf("empty p50 is %v, want 0", p)
+	}
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	for _, tc := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Microsecond},
+		{50, 500 * time.Microsecond},
+		{99, 990 * time.Microsecond},
+		{100, 1000 * time.Microsecond},
+	} {
+		got := h.Percentile(tc.p)
+		// the top of a bucket is at most 12.5% over any duration in it
+		if got < tc.want || float64(got) > float64(tc.want)*1.125 {
+			t.Errorf("p%v is %v, want %v within 12.5%%", tc.p, got, tc.want)
+		}
+	}
+
+	h = new(uidgo.LatencyHistogram)
+	for i := 0; i < 100; i++ {
+		h.Record(3)
+	}
+	if p := h.Percentile(99); p != 3 {
+		t.Errorf("small durations are exact, p99 is %v, want 3ns", p)
+	}
+}
+
+func TestWithLatencyTracking(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithLatencyTracking())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err = generator.GenerateId2(); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	p50, p99 := generator.LatencyPercentile(50), generator.LatencyPercentile(99)
+	if p50 <= 0 || p99 < p50 {
+		t.Errorf("p50(%v) & p99(%v) should be positive and ordered", p50, p99)
+	}
+	t.Logf("p50: %v, p99: %v", p50, p99)
+}
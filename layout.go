@@ -0,0 +1,143 @@
+package uidgo
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layout is the number of bits of each field of an id, from the most significant one.
+// All fields together must fit the 63 bits under the sign bit.
+type Layout struct {
+	TimestampBits    int `json:"timestampBits"`
+	DataCenterIdBits int `json:"dataCenterIdBits"`
+	WorkerIdBits     int `json:"workerIdBits"`
+	SequenceBits     int `json:"sequenceBits"`
+}
+
+var (
+	// DefaultLayout is the classic 41/5/5/12 split, 1024 nodes generating up to 4096 ids per millisecond each
+	DefaultLayout = Layout{timestampBits, dataCenterIdBits, workerIdBits, seqBits}
+	// LayoutHighThroughput is a 41/4/4/14 split, 256 nodes generating up to 16384 ids per millisecond each
+	LayoutHighThroughput = Layout{41, 4, 4, 14}
+	// LayoutManyNodes is a 41/6/6/10 split, 4096 nodes generating up to 1024 ids per millisecond each
+	LayoutManyNodes = Layout{41, 6, 6, 10}
+)
+
+// Validate checks every field has a sane width and the fields fit 63 bits
+func (l Layout) Validate() error {
+	if l.TimestampBits < 1 || l.SequenceBits < 1 {
+		return fmt.Errorf("timestamp and sequence should have at least 1 bit, layout is %+v", l)
+	}
+	if l.DataCenterIdBits < 0 || l.WorkerIdBits < 0 {
+		return fmt.Errorf("dataCenterId and workerId bits should not be negative, layout is %+v", l)
+	}
+	if n := l.TimestampBits + l.DataCenterIdBits + l.WorkerIdBits + l.SequenceBits; n > 63 {
+		return fmt.Errorf("layout takes %d bits, should be at most 63", n)
+	}
+	return nil
+}
+
+// MaxNodes returns how many generators can run side by side, one per combined node id
+func (l Layout) MaxNodes() int64 {
+	return 1 << (l.DataCenterIdBits + l.WorkerIdBits)
+}
+
+// Capacity returns how many ids a single node can generate per millisecond
+func (l Layout) Capacity() int64 {
+	return 1 << l.SequenceBits
+}
+
+// EpochFromSample returns the epoch in unix milliseconds of the generator that minted id at realTime
+func (l Layout) EpochFromSample(id uint64, realTime time.Time) int64 {
+	return realTime.UnixMilli() - int64(id>>l.timestampShift())
+}
+
+func (l Layout) timestampShift() int {
+	return l.DataCenterIdBits + l.WorkerIdBits + l.SequenceBits
+}
+
+func (l Layout) dataCenterIdShift() int {
+	return l.WorkerIdBits + l.SequenceBits
+}
+
+func (l Layout) workerIdShift() int {
+	return l.SequenceBits
+}
+
+func (l Layout) maxTimestamp() int64 {
+	return 1<<l.TimestampBits - 1
+}
+
+func (l Layout) maxDataCenterId() int64 {
+	return 1<<l.DataCenterIdBits - 1
+}
+
+func (l Layout) maxWorkerId() int64 {
+	return 1<<l.WorkerIdBits - 1
+}
+
+func (l Layout) maxSequence() int64 {
+	return 1<<l.SequenceBits - 1
+}
+
+// maxId returns the id with every field at its max value
+func (l Layout) maxId() uint64 {
+	return 1<<(l.TimestampBits+l.timestampShift()) - 1
+}
+
+// pack combines the fields into an id, tmp is the timestamp relative to the epoch
+func (l Layout) pack(tmp, dataCenterId, workerId, sequence int64) int64 {
+	return tmp<<l.timestampShift() |
+		dataCenterId<<l.dataCenterIdShift() |
+		workerId<<l.workerIdShift() |
+		sequence
+}
+
+// unpack splits id into its fields, tmp is the timestamp relative to the epoch
+func (l Layout) unpack(id uint64) (tmp, dataCenterId, workerId, sequence int64) {
+	tmp = int64(id>>l.timestampShift()) & l.maxTimestamp()
+	dataCenterId = int64(id>>l.dataCenterIdShift()) & l.maxDataCenterId()
+	workerId = int64(id>>l.workerIdShift()) & l.maxWorkerId()
+	sequence = int64(id) & l.maxSequence()
+	return
+}
+
+// Reencode moves id from one layout to another field by field, keeping the timestamp relative to the same epoch.
+// It fails with ErrReservedBitSet when id doesn't fit from, and with ErrFieldOutOfRange when a field doesn't fit to.
+func Reencode(id uint64, from, to Layout) (uint64, error) {
+	if err := from.Validate(); err != nil {
+		return 0, err
+	}
+	if err := to.Validate(); err != nil {
+		return 0, err
+	}
+	if id > from.maxId() {
+		return 0, fmt.Errorf("%w: id %d has bits set above bit %d", ErrReservedBitSet, id, from.TimestampBits+from.timestampShift()-1)
+	}
+
+	tmp, dataCenterId, workerId, sequence := from.unpack(id)
+	if tmp > to.maxTimestamp() || dataCenterId > to.maxDataCenterId() ||
+		workerId > to.maxWorkerId() || sequence > to.maxSequence() {
+		return 0, fmt.Errorf("%w: id %d has fields %d/%d/%d/%d, which don't fit layout %+v",
+			ErrFieldOutOfRange, id, tmp, dataCenterId, workerId, sequence, to)
+	}
+	return uint64(to.pack(tmp, dataCenterId, workerId, sequence)), nil
+}
+
+// OrderPreserved reports whether a and b compare the same way after a migration that packs their fields into
+// layout to, cutting every field down to its width in to, e.g. sequence 1024 becomes 0 with 10 sequence bits.
+// Two ids that fit to, see Reencode, always keep their order. It returns false for an invalid layout.
+func OrderPreserved(from, to Layout, a, b uint64) bool {
+	if from.Validate() != nil || to.Validate() != nil {
+		return false
+	}
+	ra, rb := truncate(a, from, to), truncate(b, from, to)
+	return (a < b) == (ra < rb) && (a == b) == (ra == rb)
+}
+
+// truncate moves id from one layout to another field by field, dropping the bits a field of to has no room for
+func truncate(id uint64, from, to Layout) uint64 {
+	tmp, dataCenterId, workerId, sequence := from.unpack(id)
+	return uint64(to.pack(tmp&to.maxTimestamp(), dataCenterId&to.maxDataCenterId(),
+		workerId&to.maxWorkerId(), sequence&to.maxSequence()))
+}
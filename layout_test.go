@@ -0,0 +1,126 @@
+package uidgo_test
+
+import (
+	"errors"
+	"testing"
+	"uidgo"
+)
+
+func TestLayout_Presets(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		layout   uidgo.Layout
+		nodes    int64
+		capacity int64
+	}{
+		{"DefaultLayout", uidgo.DefaultLayout, 1024, 4096},
+		{"LayoutHighThroughput", uidgo.LayoutHighThroughput, 256, 16384},
+		{"LayoutManyNodes", uidgo.LayoutManyNodes, 4096, 1024},
+	} {
+		if err := tc.layout.Validate(); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if n := tc.layout.MaxNodes(); n != tc.nodes {
+			t.Errorf("%s: MaxNodes is %d, want %d", tc.name, n, tc.nodes)
+		}
+		if c := tc.layout.Capacity(); c != tc.capacity {
+			t.Errorf("%s: Capacity is %d, want %d", tc.name, c, tc.capacity)
+		}
+	}
+
+	if err := (uidgo.Layout{42, 5, 5, 12}).Validate(); err == nil {
+		t.Error("a layout over 63 bits should be rejected")
+	}
+}
+
+func TestWithLayout(t *testing.T) {
+	l := uidgo.LayoutHighThroughput
+	var dataCenterId, workId int64 = 15, 15
+	generator, err := uidgo.NewSnowflakeSeqGenerator(dataCenterId, workId, uidgo.WithLayout(l))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if generator.MaxNodes() != l.MaxNodes() {
+		t.Errorf("MaxNodes(%d) should follow the layout(%d)", generator.MaxNodes(), l.MaxNodes())
+	}
+	if _, err = uidgo.NewSnowflakeSeqGenerator(16, 1, uidgo.WithLayout(l)); err == nil {
+		t.Error("dataCenterId 16 doesn't fit 4 bits")
+	}
+
+	// more ids than the default layout fits in a millisecond
+	ids, err := generator.GenerateIds(10000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i, id := range ids {
+		c := generator.Decode(id)
+		if c.DataCenterId != dataCenterId || c.WorkerId != workId {
+			t.Errorf("ids[%d] decodes to the wrong node(%+v)", i, c)
+		}
+		if i > 0 && id <= ids[i-1] {
+			t.Errorf("ids[%d](%d) should be greater than ids[%d](%d)", i, id, i-1, ids[i-1])
+		}
+	}
+}
+
+func TestOrderPreserved(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(3, 7)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	millis := generator.Epoch() + 1000
+	early, err := generator.GenerateIdExact(millis, 2000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	late, err := generator.GenerateIdExact(millis+1, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// 4 node bits fit dc 3 and worker 7, 14 sequence bits fit 2000
+	for _, pair := range [][2]uint64{{early, late}, {late, early}, {early, early}} {
+		if !uidgo.OrderPreserved(uidgo.DefaultLayout, uidgo.LayoutHighThroughput, pair[0], pair[1]) {
+			t.Errorf("OrderPreserved(%d, %d) should hold moving to LayoutHighThroughput", pair[0], pair[1])
+		}
+	}
+	r, err := uidgo.Reencode(early, uidgo.DefaultLayout, uidgo.LayoutHighThroughput)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	h, err := uidgo.NewSnowflakeSeqGenerator(3, 7, uidgo.WithLayout(uidgo.LayoutHighThroughput), uidgo.WithEpoch(generator.Epoch()))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if c, want := h.Decode(r), generator.Decode(early); c != want {
+		t.Errorf("re-encoded id decodes to %+v, want %+v", c, want)
+	}
+
+	// 10 sequence bits cut 1024 down to 0, below 1023 of the same millisecond
+	a, err := generator.GenerateIdExact(millis, 1023)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b, err := generator.GenerateIdExact(millis, 1024)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if uidgo.OrderPreserved(uidgo.DefaultLayout, uidgo.LayoutManyNodes, a, b) {
+		t.Errorf("OrderPreserved(%d, %d) should break moving to LayoutManyNodes", a, b)
+	}
+	if !uidgo.OrderPreserved(uidgo.DefaultLayout, uidgo.LayoutManyNodes, a, late) {
+		t.Errorf("OrderPreserved(%d, %d) should hold, both fit LayoutManyNodes", a, late)
+	}
+	if _, err = uidgo.Reencode(b, uidgo.DefaultLayout, uidgo.LayoutManyNodes); !errors.Is(err, uidgo.ErrFieldOutOfRange) {
+		t.Errorf("Reencode should fail with ErrFieldOutOfRange, got %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+module uidgo/msgpackid
+
+go 1.19
+
+require (
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	uidgo v0.0.0
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+
+replace uidgo => ../
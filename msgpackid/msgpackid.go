@@ -0,0 +1,76 @@
+// Package msgpackid encodes uidgo ids with github.com/vmihailenco/msgpack/v5.
+// It is a separate module so the uidgo package doesn't depend on msgpack.
+package msgpackid
+
+import (
+	"fmt"
+	"strconv"
+	"uidgo"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ID encodes as a msgpack uint64
+type ID uidgo.ID
+
+// StringID encodes as a msgpack decimal string, for consumers that can't hold a 64-bit integer
+// (e.g. JavaScript decoding into a float64 number)
+type StringID uidgo.ID
+
+var (
+	_ msgpack.CustomEncoder = ID(0)
+	_ msgpack.CustomDecoder = (*ID)(nil)
+	_ msgpack.CustomEncoder = StringID(0)
+	_ msgpack.CustomDecoder = (*StringID)(nil)
+)
+
+func (id ID) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.EncodeUint(uint64(id))
+}
+
+// DecodeMsgpack accepts both the integer and the string form
+func (id *ID) DecodeMsgpack(dec *msgpack.Decoder) error {
+	v, err := decode(dec)
+	if err != nil {
+		return err
+	}
+	*id = ID(v)
+	return nil
+}
+
+func (id StringID) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.EncodeString(strconv.FormatUint(uint64(id), 10))
+}
+
+// DecodeMsgpack accepts both the integer and the string form
+func (id *StringID) DecodeMsgpack(dec *msgpack.Decoder) error {
+	v, err := decode(dec)
+	if err != nil {
+		return err
+	}
+	*id = StringID(v)
+	return nil
+}
+
+func decode(dec *msgpack.Decoder) (uint64, error) {
+	v, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case uint64:
+		return v, nil
+	case int64:
+		if v < 0 {
+			return 0, fmt.Errorf("invalid id %d", v)
+		}
+		return uint64(v), nil
+	case string:
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid id %q: %w", v, err)
+		}
+		return id, nil
+	}
+	return 0, fmt.Errorf("invalid id of type %T", v)
+}
@@ -0,0 +1,50 @@
+package msgpackid_test
+
+import (
+	"testing"
+	"uidgo"
+	"uidgo/msgpackid"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestRoundTrip(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	v, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	data, err := msgpack.Marshal(msgpackid.ID(v))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var id msgpackid.ID
+	if err = msgpack.Unmarshal(data, &id); err != nil || uint64(id) != v {
+		t.Errorf("unmarshaled(%d, %v), want %d", id, err, v)
+	}
+
+	data, err = msgpack.Marshal(msgpackid.StringID(v))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var s string
+	if err = msgpack.Unmarshal(data, &s); err != nil || s != uidgo.ID(v).String() {
+		t.Errorf("StringID encoded as %q(%v), want a decimal string", s, err)
+	}
+	var sid msgpackid.StringID
+	if err = msgpack.Unmarshal(data, &sid); err != nil || uint64(sid) != v {
+		t.Errorf("unmarshaled(%d, %v), want %d", sid, err, v)
+	}
+	// ID accepts the string form too
+	if err = msgpack.Unmarshal(data, &id); err != nil || uint64(id) != v {
+		t.Errorf("unmarshaled(%d, %v), want %d", id, err, v)
+	}
+}
@@ -0,0 +1,41 @@
+package uidgo
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// MultiWorker generates ids from several worker ids owned by one process, taking turns between them, to go past
+// the per-node rate cap. Every worker id must be assigned to this process alone, just like a single generator.
+type MultiWorker struct {
+	generators []*SnowflakeSeqGenerator
+	next       atomic.Uint64
+}
+
+// NewMultiWorker initiates one generator per worker id, opts apply to all of them
+func NewMultiWorker(dataCenterId int64, workerIds []int64, opts ...Option) (*MultiWorker, error) {
+	if len(workerIds) == 0 {
+		return nil, fmt.Errorf("workerIds should not be empty")
+	}
+	seen := make(map[int64]bool, len(workerIds))
+	generators := make([]*SnowflakeSeqGenerator, len(workerIds))
+	for i, workId := range workerIds {
+		if seen[workId] {
+			return nil, fmt.Errorf("workId %d is listed twice", workId)
+		}
+		seen[workId] = true
+
+		g, err := NewSnowflakeSeqGenerator(dataCenterId, workId, opts...)
+		if err != nil {
+			return nil, err
+		}
+		generators[i] = g
+	}
+	return &MultiWorker{generators: generators}, nil
+}
+
+// GenerateId generates an id from the next worker in turn, ids are unique but only ordered per worker
+func (M *MultiWorker) GenerateId() (uint64, error) {
+	i := (M.next.Add(1) - 1) % uint64(len(M.generators))
+	return M.generators[i].GenerateId2()
+}
@@ -0,0 +1,58 @@
+package uidgo_test
+
+import (
+	"sync"
+	"testing"
+	"uidgo"
+)
+
+func TestMultiWorker_GenerateId(t *testing.T) {
+	workerIds := []int64{3, 4, 5, 6}
+	multi, err := uidgo.NewMultiWorker(1, workerIds)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	const goroutines, n = 8, 2000
+	var mu sync.Mutex
+	seen := make(map[uint64]bool, goroutines*n)
+	workers := make(map[int64]int)
+	decoder, _ := uidgo.NewSnowflakeSeqGenerator(0, 0)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				id, err := multi.GenerateId()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("id(%d) was generated twice", id)
+				}
+				seen[id] = true
+				workers[decoder.Decode(id).WorkerId]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, w := range workerIds {
+		if workers[w] != goroutines*n/len(workerIds) {
+			t.Errorf("worker %d generated %d ids, want an even share", w, workers[w])
+		}
+	}
+
+	if _, err = uidgo.NewMultiWorker(1, []int64{1, 2, 1}); err == nil {
+		t.Error("a duplicate worker id should be rejected")
+	}
+	if _, err = uidgo.NewMultiWorker(1, []int64{1, 32}); err == nil {
+		t.Error("an out of range worker id should be rejected")
+	}
+}
@@ -0,0 +1,79 @@
+package uidgo
+
+import "fmt"
+
+// MaxNodes returns how many generators can run side by side, one per combined node id
+func (S *SnowflakeSeqGenerator) MaxNodes() int64 {
+	return S.layout.MaxNodes()
+}
+
+// most node ids AllNodeIds and SiblingIds list, a layout with more nodes fails rather than allocate them all
+const maxListedNodes = 1 << 20
+
+// AllNodeIds returns every valid combined node id in order, a combined node id is the dataCenterId
+// followed by the workerId, just like dataCenterId<<WorkerIdBits | workerId.
+// It fails when the layout has more than 2^20 nodes.
+func (S *SnowflakeSeqGenerator) AllNodeIds() ([]int64, error) {
+	if n := S.MaxNodes(); n > maxListedNodes {
+		return nil, fmt.Errorf("layout has %d nodes, can list at most %d", n, maxListedNodes)
+	}
+	ids := make([]int64, S.MaxNodes())
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	return ids, nil
+}
+
+// TheoreticalMaxId returns the largest id the layout can ever produce, every field at its max value
+func (S *SnowflakeSeqGenerator) TheoreticalMaxId() uint64 {
+	return S.layout.maxId()
+}
+
+// SetDataCenterId moves the generator to another datacenter, e.g. after a relabel. The next id waits for the next
+// millisecond, a stall of up to 1ms, so it is greater than every id issued before the change even when the new
+// dataCenterId is smaller. Ids of the old and new datacenter still interleave with the ids of other nodes.
+func (S *SnowflakeSeqGenerator) SetDataCenterId(id int64) error {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	if id < 0 || id > S.layout.maxDataCenterId() {
+		return fmt.Errorf("dataCenterId should between 0 and %d", S.layout.maxDataCenterId())
+	}
+	S.dataCenterId = id
+	// use up the sequence, so an id in the same millisecond overflows to the next one
+	S.sequence = S.maxSequence >> S.nonceBits
+	return nil
+}
+
+// SiblingIds returns the ids every node could have issued at the timestamp and sequence of id, in combined node
+// id order, id itself included. Their count is MaxNodes, it fails like AllNodeIds when that is more than 2^20.
+func (S *SnowflakeSeqGenerator) SiblingIds(id uint64) ([]uint64, error) {
+	l := S.layout
+	if n := l.MaxNodes(); n > maxListedNodes {
+		return nil, fmt.Errorf("layout has %d nodes, can list at most %d", n, maxListedNodes)
+	}
+	tmp, _, _, sequence := l.unpack(id)
+	ids := make([]uint64, l.MaxNodes())
+	for n := range ids {
+		node := int64(n)
+		ids[n] = uint64(l.pack(tmp, node>>l.WorkerIdBits, node&l.maxWorkerId(), sequence))
+	}
+	return ids, nil
+}
+
+// NodeKey names the node of the generator for metrics labels and registry keys: "dc<dataCenterId>-w<workerId>",
+// or "node<n>" with n the combined node id when the layout has no dataCenterId bits
+func (S *SnowflakeSeqGenerator) NodeKey() string {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	return S.layout.nodeKey(S.dataCenterId, S.workerId)
+}
+
+// nodeKey is NodeKey for any node of the layout, so messages name nodes the same way
+func (l Layout) nodeKey(dataCenterId, workerId int64) string {
+	if l.DataCenterIdBits == 0 {
+		return fmt.Sprintf("node%d", dataCenterId<<l.WorkerIdBits|workerId)
+	}
+	return fmt.Sprintf("dc%d-w%d", dataCenterId, workerId)
+}
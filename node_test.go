@@ -0,0 +1,159 @@
+package uidgo_test
+
+import (
+	"math"
+	"testing"
+	"uidgo"
+)
+
+func TestSnowflakeSeqGenerator_AllNodeIds(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ids, err := generator.AllNodeIds()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if int64(len(ids)) != generator.MaxNodes() {
+		t.Errorf("len(ids)(%d) & MaxNodes(%d) are different", len(ids), generator.MaxNodes())
+	}
+	for i, id := range ids {
+		if id != int64(i) {
+			t.Errorf("ids[%d] is %d", i, id)
+		}
+	}
+	huge, err := uidgo.NewSnowflakeSeqGenerator(0, 0, uidgo.WithLayout(uidgo.Layout{1, 30, 30, 2}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = huge.AllNodeIds(); err == nil {
+		t.Error("listing 2^60 nodes should fail")
+	}
+}
+
+func TestSnowflakeSeqGenerator_TheoreticalMaxId(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// a signed bigint column holds every id
+	if max := generator.TheoreticalMaxId(); max != math.MaxInt64 {
+		t.Errorf("max id(%d) should be math.MaxInt64", max)
+	}
+
+	generator, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithLayout(uidgo.Layout{31, 5, 5, 12}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if max := generator.TheoreticalMaxId(); max != 1<<53-1 {
+		t.Errorf("max id(%d) should be 2^53-1", max)
+	}
+}
+
+func TestSnowflakeSeqGenerator_SetDataCenterId(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(31, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 10; i++ {
+		x, err := generator.GenerateId2()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		dataCenterId := int64(31 - i%2*31)
+		if err = generator.SetDataCenterId(dataCenterId); err != nil {
+			t.Error(err)
+			return
+		}
+		y, err := generator.GenerateId2()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if y <= x {
+			t.Errorf("y(%d) should be greater than x(%d) after the change", y, x)
+		}
+		if c := generator.Decode(y); c.DataCenterId != dataCenterId {
+			t.Errorf("y(%d) has dataCenterId %d, want %d", y, c.DataCenterId, dataCenterId)
+		}
+	}
+	if err = generator.SetDataCenterId(32); err == nil {
+		t.Error("dataCenterId 32 doesn't fit 5 bits")
+	}
+}
+
+func TestSnowflakeSeqGenerator_SiblingIds(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(3, 7)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	id, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	siblings, err := generator.SiblingIds(id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if int64(len(siblings)) != generator.MaxNodes() {
+		t.Errorf("%d siblings, want MaxNodes(%d)", len(siblings), generator.MaxNodes())
+	}
+	want := generator.Decode(id)
+	seen := make(map[uint64]bool)
+	for _, s := range siblings {
+		c := generator.Decode(s)
+		if c.Timestamp != want.Timestamp || c.Sequence != want.Sequence {
+			t.Errorf("sibling(%d) decodes to %+v, want the time and sequence of %+v", s, c, want)
+		}
+		seen[s] = true
+	}
+	if len(seen) != len(siblings) || !seen[id] {
+		t.Error("siblings should be distinct and include the id")
+	}
+	huge, err := uidgo.NewSnowflakeSeqGenerator(0, 0, uidgo.WithLayout(uidgo.Layout{1, 30, 30, 2}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = huge.SiblingIds(0); err == nil {
+		t.Error("listing 2^60 siblings should fail")
+	}
+}
+
+func TestSnowflakeSeqGenerator_NodeKey(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(3, 17)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if key := generator.NodeKey(); key != "dc3-w17" {
+		t.Errorf("NodeKey is %q, want dc3-w17", key)
+	}
+	if err = generator.SetDataCenterId(4); err != nil {
+		t.Error(err)
+		return
+	}
+	if key := generator.NodeKey(); key != "dc4-w17" {
+		t.Errorf("NodeKey is %q after SetDataCenterId, want dc4-w17", key)
+	}
+
+	combined, err := uidgo.NewSnowflakeSeqGenerator(0, 700, uidgo.WithLayout(uidgo.Layout{TimestampBits: 41, WorkerIdBits: 10, SequenceBits: 12}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if key := combined.NodeKey(); key != "node700" {
+		t.Errorf("NodeKey is %q, want node700", key)
+	}
+}
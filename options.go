@@ -0,0 +1,222 @@
+package uidgo
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// BatchErrorPolicy decides what GenerateIds returns when generation fails midway
+type BatchErrorPolicy int
+
+const (
+	// PartialResults returns the ids generated before the error, it is the default
+	PartialResults BatchErrorPolicy = iota
+	// AllOrNothing returns no ids at all
+	AllOrNothing
+)
+
+// Option configures a SnowflakeSeqGenerator in NewSnowflakeSeqGenerator
+type Option func(*SnowflakeSeqGenerator) error
+
+// WithUniquenessChecker checks every generated id with fn, which returns false if the id was seen before.
+// A rejected id is logged and the generator advances to the next id, giving up after a few attempts.
+// fn is called under the generator lock for every id, so its cost (often a round trip to the backing store)
+// is added to each generate call and caps the throughput of the generator.
+func WithUniquenessChecker(fn func(uint64) bool) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		S.uniquenessChecker = fn
+		return nil
+	}
+}
+
+// WithMaxSequence caps the sequence at max, so at most max+1 ids are generated per millisecond before waiting
+// for the next one. Decoding is unaffected since the higher sequence values are simply never used.
+// With WithNonceBits, max must be 2^m-1 so the nonce fits in the high bits under it.
+func WithMaxSequence(max int64) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if max < 0 {
+			return fmt.Errorf("max sequence(%d) should not be negative", max)
+		}
+		S.maxSequence = max
+		return nil
+	}
+}
+
+// WithEpoch sets the beginning time in unix milliseconds, the default is the start of the current year.
+// Every generator sharing an id space must use the same epoch.
+func WithEpoch(epochMillis int64) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if epochMillis < 0 {
+			return fmt.Errorf("epoch(%d) should not be negative", epochMillis)
+		}
+		S.epoch.Store(epochMillis)
+		return nil
+	}
+}
+
+// WithTimeSource reads the current time from ts instead of the wall clock
+func WithTimeSource(ts TimeSource) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if ts == nil {
+			return fmt.Errorf("time source should not be nil")
+		}
+		S.timeSource = ts
+		return nil
+	}
+}
+
+// WithFallbackTimeSource switches to secondary after the primary time source moved backwards in triggerAfter
+// consecutive generate calls. The call that triggers the switch retries on secondary, and the generator never
+// switches back. Use ActiveTimeSource to check which source is in use.
+func WithFallbackTimeSource(secondary TimeSource, triggerAfter int) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if secondary == nil {
+			return fmt.Errorf("fallback time source should not be nil")
+		}
+		if triggerAfter < 1 {
+			return fmt.Errorf("triggerAfter(%d) should be at least 1", triggerAfter)
+		}
+		S.fallbackTimeSource = secondary
+		S.fallbackTrigger = triggerAfter
+		return nil
+	}
+}
+
+// WithNonceBits fills the high n bits of the sequence field with random bits, so ids within a millisecond
+// can't be guessed from each other. The low bits still count up and keep the ids unique, but only
+// 2^(SequenceBits-n) ids fit in a millisecond. With WithMaxSequence(2^m-1), the nonce takes the high n of the
+// m bits under the cap, leaving 2^(m-n) ids per millisecond. Ids stay sorted by time across
+// milliseconds, within a millisecond their order is random.
+func WithNonceBits(n int) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if n < 0 {
+			return fmt.Errorf("nonce bits(%d) should not be negative", n)
+		}
+		S.nonceBits = n
+		return nil
+	}
+}
+
+// WithNonceSeed seeds the random source of WithNonceBits, for reproducible tests
+func WithNonceSeed(seed int64) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		S.rand = rand.New(rand.NewSource(seed))
+		return nil
+	}
+}
+
+// WithBatchErrorPolicy sets what GenerateIds returns when generation fails midway, the default is PartialResults
+func WithBatchErrorPolicy(policy BatchErrorPolicy) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if policy != PartialResults && policy != AllOrNothing {
+			return fmt.Errorf("unknown batch error policy %d", policy)
+		}
+		S.batchErrorPolicy = policy
+		return nil
+	}
+}
+
+// WithLayout splits the id bits as l instead of DefaultLayout, dataCenterId and workId are checked against it.
+// Every generator sharing an id space must use the same layout.
+func WithLayout(l Layout) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if err := l.Validate(); err != nil {
+			return err
+		}
+		S.layout = l
+		return nil
+	}
+}
+
+// WithOnGenerate calls fn with every issued id, e.g. to feed a trace or an audit log. fn runs after the lock
+// is released, so a slow fn slows down its own caller but never the other callers of the generator.
+// Ids a caller never receives, like the rest of a GenerateN chunk after a sink error or a batch dropped by
+// AllOrNothing, are not passed to fn.
+func WithOnGenerate(fn func(id uint64)) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		S.onGenerate = fn
+		return nil
+	}
+}
+
+// WithEntropyFunc takes the nonce bits of WithNonceBits from the low bits of fn instead of math/rand, e.g. to use
+// crypto/rand when ids must be unpredictable to outside parties. fn is only called when nonce bits are configured,
+// once per id under the generator lock, so a slow source like crypto/rand lowers the throughput of the generator.
+func WithEntropyFunc(fn func() int64) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		S.entropy = fn
+		return nil
+	}
+}
+
+// WithHeartbeatInterval sets how often a generator built by NewWithStore renews its worker id, the default
+// is 10s. It should be well below the lease TTL of the store.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if d <= 0 {
+			return fmt.Errorf("heartbeat interval(%v) should be positive", d)
+		}
+		S.heartbeatInterval = d
+		return nil
+	}
+}
+
+// WithLatencyTracking records how long every generate call takes in a LatencyHistogram, read it with
+// LatencyPercentile. Batch calls like GenerateIds count as one call.
+func WithLatencyTracking() Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		S.latency = new(LatencyHistogram)
+		return nil
+	}
+}
+
+// WithClockSanityCheck makes NewSnowflakeSeqGenerator fail when the time source is more than maxSkew away from
+// reference, e.g. an NTP query, so a node with a badly wrong clock never issues far-future or backward ids.
+// reference is called once, at construction.
+func WithClockSanityCheck(reference func() (time.Time, error), maxSkew time.Duration) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if reference == nil {
+			return fmt.Errorf("clock reference should not be nil")
+		}
+		if maxSkew < 0 {
+			return fmt.Errorf("max skew(%v) should not be negative", maxSkew)
+		}
+		S.clockReference = reference
+		S.clockMaxSkew = maxSkew
+		return nil
+	}
+}
+
+// WithSequenceStride multiplies the sequence counter by stride, modulo the number of sequence values, so consecutive
+// ids of a millisecond land far apart and spread inserts over more B-tree pages. The stride must be coprime with
+// the number of sequence values (any odd stride for the default 4096) to keep ids unique. Ids stay sorted by
+// time across milliseconds, within a millisecond they are no longer in generation order.
+func WithSequenceStride(stride int64) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if stride < 1 {
+			return fmt.Errorf("sequence stride(%d) should be at least 1", stride)
+		}
+		S.stride = stride
+		return nil
+	}
+}
+
+// WithClockStrategy sets what the generator does when the clock moves backwards, the default is StrategyError.
+// With WithFallbackTimeSource, the strategy applies once the generator is done switching time sources.
+func WithClockStrategy(strategy ClockStrategy, params ClockParams) Option {
+	return func(S *SnowflakeSeqGenerator) error {
+		if strategy < StrategyError || strategy > StrategyRecovery {
+			return fmt.Errorf("unknown clock strategy %d", strategy)
+		}
+		if params.Tolerance < 0 || params.MaxWait < 0 {
+			return fmt.Errorf("clock params(%+v) should not be negative", params)
+		}
+		if strategy == StrategyToleranceThenError && params.Tolerance == 0 {
+			return fmt.Errorf("StrategyToleranceThenError needs a tolerance")
+		}
+		S.clockStrategy = strategy
+		S.clockParams = params
+		return nil
+	}
+}
@@ -0,0 +1,310 @@
+package uidgo_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"uidgo"
+)
+
+func TestWithUniquenessChecker(t *testing.T) {
+	var calls int
+	var rejected uint64
+	checker := func(id uint64) bool {
+		calls++
+		if calls == 1 {
+			rejected = id
+			return false
+		}
+		return true
+	}
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithUniquenessChecker(checker))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	id, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if calls != 2 {
+		t.Errorf("checker called %d times, want 2", calls)
+	}
+	if id <= rejected {
+		t.Errorf("id(%d) should be after the rejected id(%d)", id, rejected)
+	}
+
+	generator, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithUniquenessChecker(func(uint64) bool { return false }))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = generator.GenerateId2(); err == nil {
+		t.Error("a checker rejecting every id should fail the generate call")
+	}
+}
+
+func TestWithMaxSequence(t *testing.T) {
+	var max int64 = 1
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithMaxSequence(max))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var x uint64
+	millis := make(map[int64]bool)
+	for i := 0; i < 20; i++ {
+		y, err := generator.GenerateId2()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if y <= x {
+			t.Errorf("y(%d) should be greater than x(%d)", y, x)
+		}
+		c := generator.Decode(y)
+		if c.Sequence > max {
+			t.Errorf("sequence(%d) is over the max(%d)", c.Sequence, max)
+		}
+		millis[c.Timestamp] = true
+		x = y
+	}
+	if len(millis) < 10 {
+		t.Errorf("20 ids spread over %d milliseconds, want at least 10", len(millis))
+	}
+
+	for _, max = range []int64{-1, 4096} {
+		if _, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithMaxSequence(max)); err == nil {
+			t.Errorf("max sequence %d should be rejected", max)
+		}
+	}
+}
+
+func TestWithEpoch(t *testing.T) {
+	if _, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(-1)); err == nil {
+		t.Error("a negative epoch should be rejected")
+	}
+
+	future := time.Now().Add(time.Hour).UnixMilli()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(future))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = generator.GenerateId2(); err == nil {
+		t.Error("generating before the epoch should fail")
+	}
+}
+
+func TestWithNonceBits(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithNonceBits(4), uidgo.WithNonceSeed(1))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	seen := make(map[uint64]bool)
+	var x uint64
+	var unordered int
+	for i := 0; i < 5000; i++ {
+		y, err := generator.GenerateId2()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if seen[y] {
+			t.Errorf("y(%d) was generated twice", y)
+		}
+		seen[y] = true
+		if y < x {
+			unordered++
+		}
+		x = y
+	}
+	if unordered == 0 {
+		t.Error("ids within a millisecond should not be in order")
+	}
+
+	for _, n := range []int{-1, 12} {
+		if _, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithNonceBits(n)); err == nil {
+			t.Errorf("nonce bits %d should be rejected", n)
+		}
+	}
+	// the nonce goes in the high bits under a max sequence of 2^m-1
+	capped, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithMaxSequence(127), uidgo.WithNonceBits(4))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 100; i++ {
+		id, err := capped.GenerateId2()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if c, err := capped.StrictDecode(id); err != nil || c.Sequence > 127 {
+			t.Errorf("id(%d) has sequence %d over the max 127(%v)", id, c.Sequence, err)
+		}
+	}
+	for _, max := range []int64{100, 7} {
+		if _, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithMaxSequence(max), uidgo.WithNonceBits(4)); err == nil {
+			t.Errorf("max sequence %d should be rejected with 4 nonce bits", max)
+		}
+	}
+}
+
+func TestWithOnGenerate(t *testing.T) {
+	seen := make(map[uint64]int)
+	var generator *uidgo.SnowflakeSeqGenerator
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithOnGenerate(func(id uint64) {
+		seen[id]++
+		// the lock is released, so the hook may use the generator
+		generator.Snapshot()
+	}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	issued, err := generator.GenerateIds(10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = generator.GenerateN(300, func(id uint64) error {
+		issued = append(issued, id)
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 10; i++ {
+		id, err := generator.GenerateId2()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		issued = append(issued, id)
+		if id, _, err = generator.GenerateIdWithComponents(); err != nil {
+			t.Error(err)
+			return
+		}
+		issued = append(issued, id)
+	}
+
+	if len(seen) != len(issued) {
+		t.Errorf("hook saw %d ids, want %d", len(seen), len(issued))
+	}
+	for _, id := range issued {
+		if seen[id] != 1 {
+			t.Errorf("hook saw id(%d) %d times", id, seen[id])
+		}
+	}
+
+	// ids no caller receives are not passed to the hook
+	stop := errors.New("stop")
+	before := len(seen)
+	err = generator.GenerateN(300, func(uint64) error {
+		if len(seen) == before+3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || len(seen) != before+3 {
+		t.Errorf("GenerateN(%v): hook saw %d ids, want the 3 the sink got", err, len(seen)-before)
+	}
+	var hooked int
+	failing, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(failingClock(5)),
+		uidgo.WithBatchErrorPolicy(uidgo.AllOrNothing), uidgo.WithOnGenerate(func(uint64) { hooked++ }))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = failing.GenerateIds(10); err == nil || hooked != 0 {
+		t.Errorf("GenerateIds(%v): hook saw %d ids of a dropped batch", err, hooked)
+	}
+}
+
+func TestWithEntropyFunc(t *testing.T) {
+	var calls int
+	entropy := func() int64 {
+		calls++
+		// only the low 4 bits are used
+		return 0x7f0 | 0b1011
+	}
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithNonceBits(4), uidgo.WithEntropyFunc(entropy))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 10; i++ {
+		id, c, err := generator.GenerateIdWithComponents()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// the nonce takes the high 4 of the 12 sequence bits
+		if nonce := c.Sequence >> 8; nonce != 0b1011 {
+			t.Errorf("id(%d) has nonce %b, want 1011", id, nonce)
+		}
+	}
+	if calls != 10 {
+		t.Errorf("entropy called %d times, want 10", calls)
+	}
+
+	calls = 0
+	generator, err = uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEntropyFunc(entropy))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = generator.GenerateId2(); err != nil {
+		t.Error(err)
+	}
+	if calls != 0 {
+		t.Error("entropy should not be called without nonce bits")
+	}
+}
+
+func TestWithSequenceStride(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithMaxSequence(15), uidgo.WithSequenceStride(5))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	seen := make(map[uint64]bool)
+	var x uint64
+	var unordered int
+	for i := 0; i < 2000; i++ {
+		y, c, err := generator.GenerateIdWithComponents()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if seen[y] {
+			t.Errorf("y(%d) was generated twice", y)
+		}
+		seen[y] = true
+		if c.Sequence > 15 {
+			t.Errorf("sequence(%d) is over the max", c.Sequence)
+		}
+		if y < x {
+			unordered++
+		}
+		x = y
+	}
+	if unordered == 0 {
+		t.Error("a stride should shuffle ids within a millisecond")
+	}
+
+	for _, opts := range [][]uidgo.Option{
+		{uidgo.WithSequenceStride(2)},
+		{uidgo.WithSequenceStride(6), uidgo.WithMaxSequence(15)},
+		{uidgo.WithSequenceStride(0)},
+	} {
+		if _, err = uidgo.NewSnowflakeSeqGenerator(1, 1, opts...); err == nil {
+			t.Error("a stride sharing a factor with the sequence values should be rejected")
+		}
+	}
+}
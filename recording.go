@@ -0,0 +1,50 @@
+package uidgo
+
+import (
+	"fmt"
+	"sync"
+)
+
+// RecordingGenerator generates through a generator and keeps every id it hands out, for assertions in tests
+type RecordingGenerator struct {
+	g   *SnowflakeSeqGenerator
+	mu  sync.Mutex
+	ids []uint64
+}
+
+// NewRecordingGenerator records the ids generated through g
+func NewRecordingGenerator(g *SnowflakeSeqGenerator) *RecordingGenerator {
+	return &RecordingGenerator{g: g}
+}
+
+// GenerateId generates an id and records it
+func (R *RecordingGenerator) GenerateId() (uint64, error) {
+	id, err := R.g.GenerateId2()
+	if err != nil {
+		return 0, err
+	}
+	R.mu.Lock()
+	R.ids = append(R.ids, id)
+	R.mu.Unlock()
+	return id, nil
+}
+
+// Ids returns a copy of the recorded ids, in the order they were recorded
+func (R *RecordingGenerator) Ids() []uint64 {
+	R.mu.Lock()
+	defer R.mu.Unlock()
+	return append([]uint64(nil), R.ids...)
+}
+
+// AssertNoFutureIds checks no recorded id has a timestamp after now in unix milliseconds,
+// the error reports the first one that does
+func (R *RecordingGenerator) AssertNoFutureIds(now int64) error {
+	R.mu.Lock()
+	defer R.mu.Unlock()
+	for i, id := range R.ids {
+		if c := R.g.Decode(id); c.Timestamp > now {
+			return fmt.Errorf("id %d at index %d is from %d, %dms after now(%d)", id, i, c.Timestamp, c.Timestamp-now, now)
+		}
+	}
+	return nil
+}
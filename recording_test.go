@@ -0,0 +1,51 @@
+package uidgo_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"uidgo"
+)
+
+func TestRecordingGenerator_AssertNoFutureIds(t *testing.T) {
+	clock := newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	recorder := uidgo.NewRecordingGenerator(generator)
+	now := clock.UnixMilli()
+	for i := 0; i < 3; i++ {
+		if _, err = recorder.GenerateId(); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err = recorder.AssertNoFutureIds(now); err != nil {
+		t.Errorf("ids at now should pass, got %v", err)
+	}
+
+	// a clock source running an hour ahead
+	clock.Add(time.Hour)
+	future, err := recorder.GenerateId()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = recorder.GenerateId(); err != nil {
+		t.Error(err)
+		return
+	}
+	if ids := recorder.Ids(); len(ids) != 5 || ids[3] != future {
+		t.Errorf("recorded ids(%v) should hold all 5 ids", ids)
+	}
+	err = recorder.AssertNoFutureIds(now)
+	if err == nil {
+		t.Error("ids an hour ahead should fail the assertion")
+		return
+	}
+	if !strings.Contains(err.Error(), "index 3") {
+		t.Errorf("error(%v) should report the first future id at index 3", err)
+	}
+}
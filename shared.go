@@ -0,0 +1,45 @@
+package uidgo
+
+import "sync/atomic"
+
+// SharedGenerator is for legacy setups where several logical generators must share one node id: every handle
+// generates through the same lock and counter, so no two handles issue the same sequence in the same millisecond.
+// All handles are serialized by that one lock, so together they are no faster than a single generator.
+type SharedGenerator struct {
+	g *SnowflakeSeqGenerator
+}
+
+// NewSharedGenerator initiates the generator behind all handles
+func NewSharedGenerator(dataCenterId, workId int64, opts ...Option) (*SharedGenerator, error) {
+	g, err := NewSnowflakeSeqGenerator(dataCenterId, workId, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedGenerator{g: g}, nil
+}
+
+// Handle returns a new entry point into the shared generator
+func (G *SharedGenerator) Handle() *SharedHandle {
+	return &SharedHandle{shared: G}
+}
+
+// SharedHandle is one logical generator of a SharedGenerator
+type SharedHandle struct {
+	shared *SharedGenerator
+	issued atomic.Uint64
+}
+
+// GenerateId generates an id from the shared counter
+func (H *SharedHandle) GenerateId() (uint64, error) {
+	id, err := H.shared.g.GenerateId2()
+	if err != nil {
+		return 0, err
+	}
+	H.issued.Add(1)
+	return id, nil
+}
+
+// Issued returns how many ids the handle generated
+func (H *SharedHandle) Issued() uint64 {
+	return H.issued.Load()
+}
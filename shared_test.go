@@ -0,0 +1,49 @@
+package uidgo_test
+
+import (
+	"sync"
+	"testing"
+	"uidgo"
+)
+
+func TestSharedGenerator(t *testing.T) {
+	shared, err := uidgo.NewSharedGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	handles := []*uidgo.SharedHandle{shared.Handle(), shared.Handle()}
+
+	const goroutines, n = 4, 5000
+	var mu sync.Mutex
+	seen := make(map[uint64]bool, len(handles)*goroutines*n)
+	var wg sync.WaitGroup
+	for _, h := range handles {
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(h *uidgo.SharedHandle) {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					id, err := h.GenerateId()
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					if seen[id] {
+						t.Errorf("id(%d) was generated twice", id)
+					}
+					seen[id] = true
+					mu.Unlock()
+				}
+			}(h)
+		}
+	}
+	wg.Wait()
+
+	for i, h := range handles {
+		if h.Issued() != goroutines*n {
+			t.Errorf("handle %d issued %d ids, want %d", i, h.Issued(), goroutines*n)
+		}
+	}
+}
@@ -2,7 +2,12 @@ package uidgo
 
 import (
 	"fmt"
+	"log"
+	"math/bits"
+	"math/rand"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,23 +28,12 @@ const (
 	// sequence occupancy bits
 	seqBits = 12
 
-	// timestamp max value, just like 2^41-1 = 2199023255551
-	timestampMaxValue = (1 << timestampBits) - 1
-	// dataCenterId max value, just like 2^5-1 = 31
-	dataCenterIdMaxValue = (1 << dataCenterIdBits) - 1
-	// workId max value, just like 2^5-1 = 31
-	workerIdMaxValue = (1 << workerIdBits) - 1
-	// sequence max value, just like 2^12-1 = 4095
-	seqMaxValue = (1 << seqBits) - 1
-
-	// number of workId offsets (seqBits)
-	workIdShift = 12
-	// number of dataCenterId offsets (seqBits + workerIdBits)
-	dataCenterIdShift = 17
-	// number of timestamp offsets (seqBits + workerIdBits + dataCenterIdBits)
-	timestampShift = 22
-
 	defaultInitValue = 0
+
+	// number of extra attempts after the uniqueness checker rejects an id
+	uniquenessMaxRetries = 8
+	// number of ids GenerateN generates per lock
+	generateNChunk = 256
 )
 
 type SnowflakeSeqGenerator struct {
@@ -47,135 +41,421 @@ type SnowflakeSeqGenerator struct {
 	dataCenterId int64
 	workerId     int64
 	sequence     int64
-	mu           *sync.Mutex
+	// the sequence field of the last id, the sequence counter plus any nonce bits
+	seqField    int64
+	mu          *sync.Mutex
+	maxSequence int64
+	// read without S.mu by the decoding methods, RebaseEpoch may move it
+	epoch      atomic.Int64
+	timeSource TimeSource
+	layout     Layout
+
+	uniquenessChecker func(uint64) bool
+	onGenerate        func(uint64)
+
+	batchErrorPolicy BatchErrorPolicy
+
+	nonceBits int
+	rand      *rand.Rand
+	entropy   func() int64
+	stride    int64
+
+	clockReference func() (time.Time, error)
+	clockMaxSkew   time.Duration
+
+	fallbackTimeSource TimeSource
+	fallbackTrigger    int
+	fallbackActive     bool
+	// consecutive backward clock events seen on the primary time source
+	backwardEvents int
+	clockStrategy  ClockStrategy
+	clockParams    ClockParams
+	// latest reading of the time source, timestamp only runs past it on milliseconds borrowed by StrategyRecovery
+	clockHigh int64
+
+	latency *LatencyHistogram
+
+	heartbeatInterval time.Duration
+	lease             *lease
+	closed            bool
 }
 
 // NewSnowflakeSeqGenerator initiates the snowflake generator
-func NewSnowflakeSeqGenerator(dataCenterId, workId int64) (r *SnowflakeSeqGenerator, err error) {
-	if dataCenterId < 0 || dataCenterId > dataCenterIdMaxValue {
-		err = fmt.Errorf("dataCenterId should between 0 and %d", dataCenterIdMaxValue-1)
+func NewSnowflakeSeqGenerator(dataCenterId, workId int64, opts ...Option) (r *SnowflakeSeqGenerator, err error) {
+	r = &SnowflakeSeqGenerator{
+		mu:           new(sync.Mutex),
+		timestamp:    defaultInitValue - 1,
+		dataCenterId: dataCenterId,
+		workerId:     workId,
+		sequence:     defaultInitValue,
+		maxSequence:  -1,
+		timeSource:   systemClock{},
+		layout:       DefaultLayout,
+		stride:       1,
+
+		heartbeatInterval: defaultHeartbeatInterval,
+	}
+	r.epoch.Store(epoch)
+	for _, opt := range opts {
+		if err = opt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	// options may change the layout, so the fields are checked against it afterwards
+	l := r.layout
+	if dataCenterId < 0 || dataCenterId > l.maxDataCenterId() {
+		err = fmt.Errorf("dataCenterId should between 0 and %d", l.maxDataCenterId())
 		return nil, err
 	}
 
-	if workId < 0 || workId > workerIdMaxValue {
-		err = fmt.Errorf("workId should between 0 and %d", dataCenterIdMaxValue-1)
+	if workId < 0 || workId > l.maxWorkerId() {
+		err = fmt.Errorf("workId should between 0 and %d", l.maxWorkerId())
 		return nil, err
 	}
 
-	return &SnowflakeSeqGenerator{
-		mu:           new(sync.Mutex),
-		timestamp:    defaultInitValue - 1,
-		dataCenterId: dataCenterId,
-		workerId:     workId,
-		sequence:     defaultInitValue,
-	}, nil
+	if r.maxSequence < 0 {
+		r.maxSequence = l.maxSequence()
+	} else if r.maxSequence > l.maxSequence() {
+		err = fmt.Errorf("max sequence should between 0 and %d", l.maxSequence())
+		return nil, err
+	}
+
+	if r.nonceBits >= l.SequenceBits {
+		err = fmt.Errorf("nonce bits should between 0 and %d", l.SequenceBits-1)
+		return nil, err
+	}
+	// the nonce goes in the high bits under the max sequence, which needs it to be 2^n-1
+	if r.nonceBits > 0 && (r.maxSequence&(r.maxSequence+1) != 0 || r.nonceBits >= bits.Len64(uint64(r.maxSequence))) {
+		err = fmt.Errorf("max sequence(%d) should be 2^n-1 with n over the %d nonce bits", r.maxSequence, r.nonceBits)
+		return nil, err
+	}
+	if n := r.maxSequence>>r.nonceBits + 1; gcd(r.stride, n) != 1 {
+		err = fmt.Errorf("sequence stride(%d) should be coprime with the %d sequence values", r.stride, n)
+		return nil, err
+	}
+	r.stride %= r.maxSequence>>r.nonceBits + 1
+
+	if r.clockReference != nil {
+		if err = r.checkClock(); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.nonceBits > 0 && r.entropy == nil && r.rand == nil {
+		r.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return r, nil
 }
 
-// GenerateId timestamp + dataCenterId + workId + sequence
-func (S *SnowflakeSeqGenerator) GenerateId1() (string, error) {
+// Close stops the generator, later calls to generate an id return ErrGeneratorClosed. A generator built by
+// NewWithStore also stops renewing its worker id and releases it.
+func (S *SnowflakeSeqGenerator) Close() error {
 	S.mu.Lock()
 	defer S.mu.Unlock()
 
-	now := time.Now().UnixMilli()
+	if S.closed {
+		return nil
+	}
+	S.closed = true
+	if S.lease != nil {
+		return S.lease.release()
+	}
+	return nil
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
 
+// nextId generates the next id, the caller must hold S.mu
+func (S *SnowflakeSeqGenerator) nextId() (int64, error) {
+	if S.closed {
+		return 0, ErrGeneratorClosed
+	}
+	if S.lease != nil {
+		if err := S.lease.check(); err != nil {
+			return 0, err
+		}
+	}
+	high := S.clockHigh
+	now := S.readClock()
+
+	// behind the last timestamp but not behind an earlier reading, the clock only trails milliseconds
+	// borrowed by StrategyRecovery, which is no backward event
+	if S.timestamp > now && now < high && S.recordBackward() {
+		log.Printf("uidgo: clock moved backwards %d times in a row, switched to the fallback time source", S.backwardEvents)
+		now = S.readClock()
+	}
+	if now >= high {
+		S.backwardEvents = 0
+	}
 	if S.timestamp > now { // Clock callback
-		return "", fmt.Errorf("Clock moved backwards. Refusing to generate ID, last timestamp is %d, now is %d", S.timestamp, now)
-	} else if S.timestamp == now {
+		var err error
+		if now, err = S.handleBackward(now); err != nil {
+			return 0, err
+		}
+	}
+
+	if S.timestamp == now {
 		// generate multiple IDs in the same millisecond, incrementing the sequence number to prevent conflicts
-		S.sequence = (S.sequence + 1) & seqMaxValue
-		if S.sequence == 0 {
+		S.sequence++
+		if S.sequence > S.maxSequence>>S.nonceBits {
 			// sequence overflow, waiting for next millisecond
+			S.sequence = defaultInitValue
+			if S.clockStrategy == StrategyRecovery {
+				// the clock may be far behind, borrow the next millisecond rather than wait for it
+				now = S.timestamp + 1
+			}
 			for now <= S.timestamp {
-				now = time.Now().UnixMilli()
+				now = S.readClock()
 			}
 		}
 	} else {
 		// initialized sequences are used directly at different millisecond timestamps
 		S.sequence = defaultInitValue
 	}
-	tmp := now - epoch
-	if tmp > timestampMaxValue {
-		return "", fmt.Errorf("epoch should between 0 and %d", timestampMaxValue-1)
+	e := S.epoch.Load()
+	tmp := now - e
+	if tmp < 0 {
+		return 0, fmt.Errorf("now(%d) is before the epoch(%d)", now, e)
+	}
+	if tmp > S.layout.maxTimestamp() {
+		return 0, fmt.Errorf("epoch should between 0 and %d", S.layout.maxTimestamp()-1)
 	}
 	S.timestamp = now
+	S.seqField = S.sequence
+	if S.stride > 1 {
+		// a stride coprime with the counter space visits every counter value once per millisecond
+		S.seqField = S.sequence * S.stride % (S.maxSequence>>S.nonceBits + 1)
+	}
+	if S.nonceBits > 0 {
+		// random high bits of the sequence field, the counter in the low bits keeps the id unique
+		var nonce int64
+		if S.entropy != nil {
+			nonce = S.entropy() & (1<<S.nonceBits - 1)
+		} else {
+			nonce = S.rand.Int63n(1 << S.nonceBits)
+		}
+		S.seqField |= nonce << (bits.Len64(uint64(S.maxSequence)) - S.nonceBits)
+	}
 
 	// combine the parts to generate the final ID and convert the 64-bit binary to decimal digits.
-	r := (tmp)<<timestampShift |
-		(S.dataCenterId << dataCenterIdShift) |
-		(S.workerId << workIdShift) |
-		(S.sequence)
+	return S.layout.pack(tmp, S.dataCenterId, S.workerId, S.seqField), nil
+}
 
-	return fmt.Sprintf("%d", r), nil
+// generate returns the next id accepted by the uniqueness checker, the caller must hold S.mu
+func (S *SnowflakeSeqGenerator) generate() (int64, error) {
+	for i := 0; ; i++ {
+		r, err := S.nextId()
+		if err != nil {
+			return 0, err
+		}
+		if S.uniquenessChecker == nil || S.uniquenessChecker(uint64(r)) {
+			return r, nil
+		}
+		log.Printf("uidgo: id %d rejected by uniqueness checker, node is %s", r, S.layout.nodeKey(S.dataCenterId, S.workerId))
+		if i >= uniquenessMaxRetries {
+			return 0, fmt.Errorf("uniqueness checker rejected %d ids in a row, last id is %d", i+1, r)
+		}
+	}
 }
 
-func (S *SnowflakeSeqGenerator) GenerateId2() (uint64, error) {
+// generateOne generates a single id under the lock and reports it to the OnGenerate hook
+func (S *SnowflakeSeqGenerator) generateOne() (int64, error) {
+	start := time.Now()
 	S.mu.Lock()
-	defer S.mu.Unlock()
+	r, err := S.generate()
+	S.mu.Unlock()
+	S.recordLatency(start)
 
-	now := time.Now().UnixMilli()
+	if err != nil {
+		return 0, err
+	}
+	S.notify(uint64(r))
+	return r, nil
+}
 
-	if S.timestamp > now { // Clock callback
-		return 0, fmt.Errorf("Clock moved backwards. Refusing to generate ID, last timestamp is %d, now is %d", S.timestamp, now)
-	} else if S.timestamp == now {
-		// generate multiple IDs in the same millisecond, incrementing the sequence number to prevent conflicts
-		S.sequence = (S.sequence + 1) & seqMaxValue
-		if S.sequence == 0 {
-			// sequence overflow, waiting for next millisecond
-			for now <= S.timestamp {
-				now = time.Now().UnixMilli()
-			}
-		}
-	} else {
-		// initialized sequences are used directly at different millisecond timestamps
-		S.sequence = defaultInitValue
+// notify calls the OnGenerate hook with an issued id, the caller must not hold S.mu
+func (S *SnowflakeSeqGenerator) notify(id uint64) {
+	if S.onGenerate != nil {
+		S.onGenerate(id)
 	}
-	tmp := now - epoch
-	if tmp > timestampMaxValue {
-		return 0, fmt.Errorf("epoch should between 0 and %d", timestampMaxValue-1)
+}
+
+// GenerateId timestamp + dataCenterId + workId + sequence
+func (S *SnowflakeSeqGenerator) GenerateId1() (string, error) {
+	r, err := S.generateOne()
+	if err != nil {
+		return "", err
 	}
-	S.timestamp = now
+	return strconv.FormatInt(r, 10), nil
+}
 
-	// combine the parts to generate the final ID and convert the 64-bit binary to decimal digits.
-	r := (tmp)<<timestampShift |
-		(S.dataCenterId << dataCenterIdShift) |
-		(S.workerId << workIdShift) |
-		(S.sequence)
+// GenerateIdStringPooled is GenerateId1, which makes the string its only allocation.
+//
+// Deprecated: use GenerateId1.
+func (S *SnowflakeSeqGenerator) GenerateIdStringPooled() (string, error) {
+	return S.GenerateId1()
+}
 
+func (S *SnowflakeSeqGenerator) GenerateId2() (uint64, error) {
+	r, err := S.generateOne()
+	if err != nil {
+		return 0, err
+	}
 	return uint64(r), nil
 }
 
 func (S *SnowflakeSeqGenerator) GenerateId3() (uint64, string, error) {
+	r, err := S.generateOne()
+	if err != nil {
+		return 0, "", err
+	}
+	return uint64(r), strconv.FormatInt(r, 10), nil
+}
+
+// GenerateIdWithComponents returns the id together with the fields it was built from, read from the generator state
+// instead of decoding the id
+func (S *SnowflakeSeqGenerator) GenerateIdWithComponents() (uint64, Components, error) {
+	start := time.Now()
 	S.mu.Lock()
-	defer S.mu.Unlock()
+	r, err := S.generate()
+	c := Components{
+		Timestamp:    S.timestamp,
+		DataCenterId: S.dataCenterId,
+		WorkerId:     S.workerId,
+		Sequence:     S.seqField,
+	}
+	S.mu.Unlock()
+	S.recordLatency(start)
 
-	now := time.Now().UnixMilli()
+	if err != nil {
+		return 0, Components{}, err
+	}
 
-	if S.timestamp > now { // Clock callback
-		return 0, "", fmt.Errorf("Clock moved backwards. Refusing to generate ID, last timestamp is %d, now is %d", S.timestamp, now)
-	} else if S.timestamp == now {
-		// generate multiple IDs in the same millisecond, incrementing the sequence number to prevent conflicts
-		S.sequence = (S.sequence + 1) & seqMaxValue
-		if S.sequence == 0 {
-			// sequence overflow, waiting for next millisecond
-			for now <= S.timestamp {
-				now = time.Now().UnixMilli()
+	S.notify(uint64(r))
+	return uint64(r), c, nil
+}
+
+// GenerateIds generates n ids under a single lock. When generation fails midway, the ids generated so far
+// are returned with the error unless the generator was built with WithBatchErrorPolicy(AllOrNothing).
+func (S *SnowflakeSeqGenerator) GenerateIds(n int) ([]uint64, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("count(%d) should not be negative", n)
+	}
+	ids, err := S.appendIds(make([]uint64, 0, n), n)
+	if err != nil && S.batchErrorPolicy == AllOrNothing {
+		return nil, err
+	}
+	for _, id := range ids {
+		S.notify(id)
+	}
+	return ids, err
+}
+
+// GenerateN generates n ids and passes them to sink in order, stopping at the first sink error.
+// Ids are generated in chunks, and sink is called with the lock released so it may block or call the generator.
+func (S *SnowflakeSeqGenerator) GenerateN(n int, sink func(uint64) error) error {
+	if n < 0 {
+		return fmt.Errorf("count(%d) should not be negative", n)
+	}
+	size := n
+	if size > generateNChunk {
+		size = generateNChunk
+	}
+	chunk := make([]uint64, 0, size)
+	for n > 0 {
+		var err error
+		chunk, err = S.appendIds(chunk[:0], size)
+		for _, id := range chunk {
+			S.notify(id)
+			if serr := sink(id); serr != nil {
+				return serr
 			}
 		}
-	} else {
-		// initialized sequences are used directly at different millisecond timestamps
-		S.sequence = defaultInitValue
+		if err != nil {
+			return err
+		}
+		n -= len(chunk)
+		if n < size {
+			size = n
+		}
 	}
-	tmp := now - epoch
-	if tmp > timestampMaxValue {
-		return 0, "", fmt.Errorf("epoch should between 0 and %d", timestampMaxValue-1)
+	return nil
+}
+
+// appendIds generates n ids under a single lock and appends them to ids, stopping at the first error.
+// The caller notifies the ids it hands out.
+func (S *SnowflakeSeqGenerator) appendIds(ids []uint64, n int) ([]uint64, error) {
+	var err error
+	start := time.Now()
+	S.mu.Lock()
+	for i := 0; i < n; i++ {
+		var r int64
+		if r, err = S.generate(); err != nil {
+			break
+		}
+		ids = append(ids, uint64(r))
 	}
-	S.timestamp = now
+	S.mu.Unlock()
+	S.recordLatency(start)
+	return ids, err
+}
 
-	// combine the parts to generate the final ID and convert the 64-bit binary to decimal digits.
-	r := (tmp)<<timestampShift |
-		(S.dataCenterId << dataCenterIdShift) |
-		(S.workerId << workIdShift) |
-		(S.sequence)
+// GenerateIdExact packs millis (unix milliseconds) and sequence with the node of the generator, for building
+// fixtures and golden tests. It neither reads nor advances the live counter, so the id may collide with
+// ids the generator issues.
+func (S *SnowflakeSeqGenerator) GenerateIdExact(millis, sequence int64) (uint64, error) {
+	S.mu.Lock()
+	defer S.mu.Unlock()
 
-	return uint64(r), fmt.Sprintf("%d", r), nil
+	tmp := millis - S.epoch.Load()
+	if tmp < 0 || tmp > S.layout.maxTimestamp() {
+		return 0, fmt.Errorf("millis(%d) should be within %d bits after the epoch(%d)", millis, S.layout.TimestampBits, S.epoch.Load())
+	}
+	if sequence < 0 || sequence > S.layout.maxSequence() {
+		return 0, fmt.Errorf("sequence should between 0 and %d", S.layout.maxSequence())
+	}
+	return uint64(S.layout.pack(tmp, S.dataCenterId, S.workerId, sequence)), nil
+}
+
+// WillRollover reports whether the next id will start a new millisecond, because the clock moved on or the
+// sequence of the current millisecond is used up. It doesn't change the generator.
+func (S *SnowflakeSeqGenerator) WillRollover() bool {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	return S.timeSource.UnixMilli() > S.timestamp || S.sequence >= S.maxSequence>>S.nonceBits
+}
+
+// Epoch returns the beginning time in unix milliseconds
+func (S *SnowflakeSeqGenerator) Epoch() int64 {
+	return S.epoch.Load()
+}
+
+// RebaseEpoch moves the epoch to newEpoch without a restart. It only succeeds when newEpoch is not after the
+// current epoch, so the timestamp field of the following ids keeps growing and no id is smaller than one
+// already issued, and when the last issued timestamp still fits the timestamp bits under newEpoch.
+// Ids issued before the rebase decode to times shifted by the difference, so every reader of the ids must
+// switch epochs at the same time.
+func (S *SnowflakeSeqGenerator) RebaseEpoch(newEpoch int64) error {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	e := S.epoch.Load()
+	if newEpoch > e {
+		return fmt.Errorf("new epoch(%d) is after the epoch(%d), ids would move backwards", newEpoch, e)
+	}
+	if newEpoch < 0 {
+		return fmt.Errorf("epoch(%d) should not be negative", newEpoch)
+	}
+	if S.timestamp-newEpoch > S.layout.maxTimestamp() {
+		return fmt.Errorf("last timestamp(%d) doesn't fit %d bits after epoch(%d)", S.timestamp, S.layout.TimestampBits, newEpoch)
+	}
+	S.epoch.Store(newEpoch)
+	return nil
 }
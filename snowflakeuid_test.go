@@ -1,7 +1,10 @@
 package uidgo_test
 
 import (
+	"errors"
+	"strconv"
 	"testing"
+	"time"
 	"uidgo"
 )
 
@@ -44,3 +47,245 @@ func TestSnowflakeSeqGenerator_GenerateId(t *testing.T) {
 		t.Logf("generate id: %v, %s", id, y)
 	}
 }
+
+func TestSnowflakeSeqGenerator_GenerateIdWithComponents(t *testing.T) {
+	var dataCenterId, workId int64 = 3, 7
+	generator, err := uidgo.NewSnowflakeSeqGenerator(dataCenterId, workId)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 100; i++ {
+		id, c, err := generator.GenerateIdWithComponents()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if d := generator.Decode(id); d != c {
+			t.Errorf("components(%+v) & decoded(%+v) are different", c, d)
+		}
+		if c.DataCenterId != dataCenterId || c.WorkerId != workId {
+			t.Errorf("components(%+v) have the wrong node", c)
+		}
+	}
+}
+
+func TestSnowflakeSeqGenerator_GenerateIds(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ids, err := generator.GenerateIds(100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(ids) != 100 {
+		t.Errorf("generated %d ids, want 100", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Errorf("ids[%d](%d) should be greater than ids[%d](%d)", i, ids[i], i-1, ids[i-1])
+		}
+	}
+	if _, err = generator.GenerateIds(-1); err == nil {
+		t.Error("a negative count should fail")
+	}
+
+	for _, tc := range []struct {
+		policy uidgo.BatchErrorPolicy
+		want   int
+	}{
+		{uidgo.PartialResults, 3},
+		{uidgo.AllOrNothing, 0},
+	} {
+		generator, err = uidgo.NewSnowflakeSeqGenerator(1, 1,
+			uidgo.WithTimeSource(failingClock(3)), uidgo.WithBatchErrorPolicy(tc.policy))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ids, err = generator.GenerateIds(5)
+		if err == nil {
+			t.Errorf("policy %d: the backward clock should fail the batch", tc.policy)
+		}
+		if len(ids) != tc.want {
+			t.Errorf("policy %d: got %d ids, want %d", tc.policy, len(ids), tc.want)
+		}
+	}
+}
+
+func TestSnowflakeSeqGenerator_RebaseEpoch(t *testing.T) {
+	epoch := time.Date(2020, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithEpoch(epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	x, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	later := time.Date(2021, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	if err = generator.RebaseEpoch(later); err == nil {
+		t.Error("a later epoch should be rejected")
+	}
+	if generator.Epoch() != epoch {
+		t.Errorf("rejected rebase changed the epoch to %d", generator.Epoch())
+	}
+
+	earlier := time.Date(2019, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	if err = generator.RebaseEpoch(earlier); err != nil {
+		t.Error(err)
+		return
+	}
+	y, c, err := generator.GenerateIdWithComponents()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if y <= x {
+		t.Errorf("y(%d) should be greater than x(%d)", y, x)
+	}
+	if d := generator.Decode(y); d != c {
+		t.Errorf("components(%+v) & decoded(%+v) are different after the rebase", c, d)
+	}
+}
+
+func TestSnowflakeSeqGenerator_GenerateN(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var ids []uint64
+	err = generator.GenerateN(1000, func(id uint64) error {
+		if len(ids) > 0 && id <= ids[len(ids)-1] {
+			t.Errorf("id(%d) should be greater than the last id(%d)", id, ids[len(ids)-1])
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(ids) != 1000 {
+		t.Errorf("sink saw %d ids, want 1000", len(ids))
+	}
+	if err = generator.GenerateN(-1, func(uint64) error { return nil }); err == nil {
+		t.Error("a negative count should fail")
+	}
+
+	stop := errors.New("stop")
+	var calls int
+	err = generator.GenerateN(1000, func(uint64) error {
+		calls++
+		if calls == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || calls != 3 {
+		t.Errorf("error(%v) after %d calls, want the sink error after 3 calls", err, calls)
+	}
+}
+
+func TestSnowflakeSeqGenerator_GenerateIdExact(t *testing.T) {
+	epoch := time.Date(2020, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(2, 3, uidgo.WithEpoch(epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := uidgo.Components{Timestamp: epoch + 123456, DataCenterId: 2, WorkerId: 3, Sequence: 42}
+	id, err := generator.GenerateIdExact(want.Timestamp, want.Sequence)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if c := generator.Decode(id); c != want {
+		t.Errorf("decoded(%+v), want %+v", c, want)
+	}
+
+	if _, err = generator.GenerateIdExact(epoch-1, 0); err == nil {
+		t.Error("millis before the epoch should be rejected")
+	}
+	if _, err = generator.GenerateIdExact(epoch, 4096); err == nil {
+		t.Error("sequence 4096 doesn't fit 12 bits")
+	}
+}
+
+func TestSnowflakeSeqGenerator_WillRollover(t *testing.T) {
+	clock := newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock), uidgo.WithMaxSequence(2))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !generator.WillRollover() {
+		t.Error("the first id starts a new millisecond")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err = generator.GenerateId2(); err != nil {
+			t.Error(err)
+			return
+		}
+		// sequences 0 and 1 leave room in the millisecond, 2 is the max
+		if got, want := generator.WillRollover(), i == 2; got != want {
+			t.Errorf("after sequence %d WillRollover is %v, want %v", i, got, want)
+		}
+	}
+	clock.Add(time.Millisecond)
+	if _, err = generator.GenerateId2(); err != nil {
+		t.Error(err)
+		return
+	}
+	if generator.WillRollover() {
+		t.Error("a new millisecond has room again")
+	}
+	clock.Add(time.Millisecond)
+	if !generator.WillRollover() {
+		t.Error("the clock moved on")
+	}
+}
+
+func TestSnowflakeSeqGenerator_GenerateIdStringPooled(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var x uint64
+	for i := 0; i < 1000; i++ {
+		s, err := generator.GenerateIdStringPooled()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		y, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if y <= x {
+			t.Errorf("y(%d) should be greater than x(%d)", y, x)
+		}
+		if c := generator.Decode(y); c.DataCenterId != 1 || c.WorkerId != 1 {
+			t.Errorf("y(%s) decodes to the wrong node(%+v)", s, c)
+		}
+		x = y
+	}
+}
+
+func BenchmarkSnowflakeSeqGenerator_GenerateId1(b *testing.B) {
+	generator, _ := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := generator.GenerateId1(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package uidgo
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// number of bytes of a State in binary form
+const stateSize = 5 * 8
+
+// State is what a generator must remember across a restart to never issue an id twice
+type State struct {
+	// unix milliseconds of the last issued id
+	Timestamp int64 `json:"timestamp"`
+	// sequence counter of the last issued id
+	Sequence     int64 `json:"sequence"`
+	DataCenterId int64 `json:"dataCenterId"`
+	WorkerId     int64 `json:"workerId"`
+	Epoch        int64 `json:"epoch"`
+}
+
+// jsonState is the checkpoint written by MarshalState
+type jsonState struct {
+	State
+	Layout Layout `json:"layout"`
+}
+
+// MarshalBinary encodes the state as 5 big-endian int64
+func (st State) MarshalBinary() ([]byte, error) {
+	data := make([]byte, stateSize)
+	for i, v := range []int64{st.Timestamp, st.Sequence, st.DataCenterId, st.WorkerId, st.Epoch} {
+		binary.BigEndian.PutUint64(data[i*8:], uint64(v))
+	}
+	return data, nil
+}
+
+func (st *State) UnmarshalBinary(data []byte) error {
+	if len(data) != stateSize {
+		return fmt.Errorf("state should be %d bytes, got %d", stateSize, len(data))
+	}
+	for i, v := range []*int64{&st.Timestamp, &st.Sequence, &st.DataCenterId, &st.WorkerId, &st.Epoch} {
+		*v = int64(binary.BigEndian.Uint64(data[i*8:]))
+	}
+	return nil
+}
+
+// Snapshot returns the current state of the generator
+func (S *SnowflakeSeqGenerator) Snapshot() State {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	return State{
+		Timestamp:    S.timestamp,
+		Sequence:     S.sequence,
+		DataCenterId: S.dataCenterId,
+		WorkerId:     S.workerId,
+		Epoch:        S.epoch.Load(),
+	}
+}
+
+// Restore continues from st, a state of the same node and epoch saved before a restart. Until the clock
+// passes st.Timestamp the generator fails like the clock moved backwards, so no id before st is issued
+// again. A state older than the current one is ignored.
+func (S *SnowflakeSeqGenerator) Restore(st State) error {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	if st.DataCenterId != S.dataCenterId || st.WorkerId != S.workerId {
+		return fmt.Errorf("state of node %s can't restore node %s",
+			S.layout.nodeKey(st.DataCenterId, st.WorkerId), S.layout.nodeKey(S.dataCenterId, S.workerId))
+	}
+	if e := S.epoch.Load(); st.Epoch != e {
+		return fmt.Errorf("state of epoch %d can't restore epoch %d", st.Epoch, e)
+	}
+	if st.Timestamp > S.timestamp || st.Timestamp == S.timestamp && st.Sequence > S.sequence {
+		S.timestamp = st.Timestamp
+		S.sequence = st.Sequence
+		// a clock behind the restored timestamp moved backwards since the state was saved
+		if st.Timestamp > S.clockHigh {
+			S.clockHigh = st.Timestamp
+		}
+	}
+	return nil
+}
+
+// MarshalState returns the state and layout of the generator as JSON, for checkpoint files people can read
+func (S *SnowflakeSeqGenerator) MarshalState() ([]byte, error) {
+	return json.Marshal(jsonState{State: S.Snapshot(), Layout: S.layout})
+}
+
+// UnmarshalState restores a checkpoint written by MarshalState, with the same checks and catch-up as Restore
+func (S *SnowflakeSeqGenerator) UnmarshalState(data []byte) error {
+	var st jsonState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	if st.Layout != S.layout {
+		return fmt.Errorf("state of layout %+v can't restore layout %+v", st.Layout, S.layout)
+	}
+	return S.Restore(st.State)
+}
+
+// SafeRestartTime returns the earliest time a generator restored from st can issue ids, the millisecond after
+// the last issued one. Startup code can sleep until then instead of failing like the clock moved backwards.
+func SafeRestartTime(st State) time.Time {
+	return time.UnixMilli(st.Timestamp + 1)
+}
+
+// StateFile returns a sink for NewPeriodicPersister that keeps the last state in the file at path. Each write goes
+// to a temporary file next to it, is synced and renamed over path, so a crash leaves either the old or the new
+// state. The state carries a checksum, LoadStateFile rejects a corrupt file.
+func StateFile(path string) func(State) error {
+	return func(st State) error {
+		data, _ := st.MarshalBinary()
+		data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+		tmp := path + ".tmp"
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(data); err == nil {
+			err = f.Sync()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+}
+
+// LoadStateFile reads the state kept by StateFile
+func LoadStateFile(path string) (State, error) {
+	var st State
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st, err
+	}
+	if len(data) != stateSize+4 {
+		return st, fmt.Errorf("state file %s should be %d bytes, got %d", path, stateSize+4, len(data))
+	}
+	if sum := binary.BigEndian.Uint32(data[stateSize:]); sum != crc32.ChecksumIEEE(data[:stateSize]) {
+		return st, fmt.Errorf("state file %s is corrupt, checksum mismatch", path)
+	}
+	err = st.UnmarshalBinary(data[:stateSize])
+	return st, err
+}
+
+// PeriodicPersister passes the state of a generator to a sink on start, every interval and on Close. Each call
+// replaces the state the sink keeps, use StateFile to keep it in a file.
+//
+// Persisting on every id is too slow, so a crash loses up to one interval of issued ids: the last persisted state
+// may trail them by up to the interval. Restore that state and wait until SafeRestartTime plus the interval
+// before generating, or a clock that moved backwards across the restart may reissue ids from that window.
+type PeriodicPersister struct {
+	g        *SnowflakeSeqGenerator
+	sink     func(State) error
+	interval time.Duration
+
+	// serializes the calls to sink
+	mu   sync.Mutex
+	err  error
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewPeriodicPersister persists the state of g to sink now, then every interval
+func NewPeriodicPersister(g *SnowflakeSeqGenerator, sink func(State) error, interval time.Duration) (*PeriodicPersister, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval(%v) should be positive", interval)
+	}
+	P := &PeriodicPersister{
+		g:        g,
+		sink:     sink,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := P.Flush(); err != nil {
+		return nil, err
+	}
+	go P.run()
+	return P, nil
+}
+
+func (P *PeriodicPersister) run() {
+	defer close(P.done)
+
+	ticker := time.NewTicker(P.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := P.Flush(); err != nil {
+				log.Printf("uidgo: persist state failed: %v", err)
+			}
+		case <-P.stop:
+			return
+		}
+	}
+}
+
+// Interval returns how long issued ids may go unpersisted
+func (P *PeriodicPersister) Interval() time.Duration {
+	return P.interval
+}
+
+// Flush persists the current state now
+func (P *PeriodicPersister) Flush() error {
+	P.mu.Lock()
+	defer P.mu.Unlock()
+
+	if err := P.sink(P.g.Snapshot()); err != nil {
+		if P.err == nil {
+			P.err = err
+		}
+		return err
+	}
+	return nil
+}
+
+// Close stops the periodic writes and persists the state a last time, it returns the first flush error if any
+func (P *PeriodicPersister) Close() error {
+	P.once.Do(func() { close(P.stop) })
+	<-P.done
+
+	err := P.Flush()
+	P.mu.Lock()
+	defer P.mu.Unlock()
+	if P.err != nil {
+		return P.err
+	}
+	return err
+}
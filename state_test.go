@@ -0,0 +1,279 @@
+package uidgo_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"uidgo"
+)
+
+// stateLog records every state persisted to it, safe for the persister goroutine
+type stateLog struct {
+	mu     sync.Mutex
+	states []uidgo.State
+}
+
+func (l *stateLog) persist(st uidgo.State) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.states = append(l.states, st)
+	return nil
+}
+
+func (l *stateLog) last() uidgo.State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.states[len(l.states)-1]
+}
+
+func must(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestSnowflakeSeqGenerator_Restore(t *testing.T) {
+	clock := newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	x, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	st := generator.Snapshot()
+
+	// restart with a clock that moved backwards
+	clock.Add(-5 * time.Millisecond)
+	restarted, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = restarted.Restore(st); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = restarted.GenerateId2(); err == nil {
+		t.Error("generating before the restored timestamp should fail")
+	}
+	clock.Add(5 * time.Millisecond)
+	y, err := restarted.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if y <= x {
+		t.Errorf("y(%d) should be greater than x(%d)", y, x)
+	}
+
+	other, err := uidgo.NewSnowflakeSeqGenerator(1, 2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = other.Restore(st); err == nil || !strings.Contains(err.Error(), "node dc1-w1 can't restore node "+other.NodeKey()) {
+		t.Errorf("restoring the state of another node should fail naming both nodes, got %v", err)
+	}
+}
+
+func TestSnowflakeSeqGenerator_MarshalState(t *testing.T) {
+	clock := newManualClock()
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = generator.GenerateIds(10); err != nil {
+		t.Error(err)
+		return
+	}
+	data, err := generator.MarshalState()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Logf("state: %s", data)
+
+	// restart with a clock that moved backwards
+	clock.Add(-5 * time.Millisecond)
+	restarted, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = restarted.UnmarshalState(data); err != nil {
+		t.Error(err)
+		return
+	}
+	if st, want := restarted.Snapshot(), generator.Snapshot(); st != want {
+		t.Errorf("restored state(%+v), want %+v", st, want)
+	}
+	if _, err = restarted.GenerateId2(); err == nil {
+		t.Error("generating before the restored timestamp should fail")
+	}
+	clock.Add(5 * time.Millisecond)
+	if _, err = restarted.GenerateId2(); err != nil {
+		t.Error(err)
+	}
+
+	other, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithLayout(uidgo.LayoutManyNodes))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = other.UnmarshalState(data); err == nil {
+		t.Error("restoring the state of another layout should fail")
+	}
+}
+
+func TestSafeRestartTime(t *testing.T) {
+	clock := newManualClock()
+	st := uidgo.State{
+		Timestamp:    clock.UnixMilli() + 100,
+		Sequence:     4,
+		DataCenterId: 1,
+		WorkerId:     1,
+		Epoch:        time.Date(2020, time.January, 01, 00, 00, 00, 00, time.UTC).UnixMilli(),
+	}
+	restart := uidgo.SafeRestartTime(st)
+	if !restart.After(time.UnixMilli(st.Timestamp)) {
+		t.Errorf("restart time(%v) should be after the state", restart)
+	}
+
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1, uidgo.WithTimeSource(clock), uidgo.WithEpoch(st.Epoch))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = generator.Restore(st); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = generator.GenerateId2(); err == nil {
+		t.Error("generating before the restart time should fail")
+	}
+	clock.millis.Store(restart.UnixMilli())
+	id, err := generator.GenerateId2()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if c := generator.Decode(id); c.Timestamp <= st.Timestamp {
+		t.Errorf("id(%d) at %d should be after the state(%d)", id, c.Timestamp, st.Timestamp)
+	}
+}
+
+func TestPeriodicPersister(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sink := new(stateLog)
+	interval := 20 * time.Millisecond
+	persister, err := uidgo.NewPeriodicPersister(generator, sink.persist, interval)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if persister.Interval() != interval {
+		t.Errorf("interval(%v) should be %v", persister.Interval(), interval)
+	}
+
+	// every id is persisted within the interval of being issued, plus one more for scheduling slack
+	var issued []uint64
+	var issuedAt []time.Time
+	checked := 0
+	for deadline := time.Now().Add(10 * interval); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		id, err := generator.GenerateId2()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		issued, issuedAt = append(issued, id), append(issuedAt, time.Now())
+		now, persisted := time.Now(), sink.last().Timestamp
+		for ; checked < len(issued) && now.Sub(issuedAt[checked]) > 2*interval; checked++ {
+			if ts := generator.Decode(issued[checked]).Timestamp; ts > persisted {
+				t.Errorf("id(%d) at %d is still after the persisted state(%d) %v later",
+					issued[checked], ts, persisted, now.Sub(issuedAt[checked]))
+			}
+		}
+	}
+	if checked == 0 {
+		t.Error("no id was old enough to check")
+	}
+	if err = persister.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// the state persisted on Close covers every issued id
+	last := sink.last()
+	for _, id := range issued {
+		if c := generator.Decode(id); c.Timestamp > last.Timestamp {
+			t.Errorf("id(%d) at %d is after the last persisted state(%d)", id, c.Timestamp, last.Timestamp)
+		}
+	}
+	if n := len(sink.states); n < 5 {
+		t.Errorf("%d states persisted, want one every interval", n)
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	generator, err := uidgo.NewSnowflakeSeqGenerator(1, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	path := filepath.Join(t.TempDir(), "uidgo.state")
+	persister, err := uidgo.NewPeriodicPersister(generator, uidgo.StateFile(path), time.Hour)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = generator.GenerateIds(10); err != nil {
+		t.Error(err)
+		return
+	}
+	if err = persister.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// the file holds only the last state
+	st, err := uidgo.LoadStateFile(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if want := generator.Snapshot(); st != want {
+		t.Errorf("loaded %+v, want %+v", st, want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data[3] ^= 1
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = uidgo.LoadStateFile(path); err == nil {
+		t.Error("a corrupt state file should be rejected")
+	}
+	if err = os.WriteFile(path, data[:20], 0o644); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = uidgo.LoadStateFile(path); err == nil {
+		t.Error("a torn state file should be rejected")
+	}
+}
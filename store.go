@@ -0,0 +1,256 @@
+package uidgo
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrNoWorkerId is returned by a WorkerIdStore when every worker id is leased
+	ErrNoWorkerId = errors.New("uidgo: no free worker id")
+	// ErrLeaseLost means the lease of a worker id expired and may belong to another node
+	ErrLeaseLost = errors.New("uidgo: worker id lease lost")
+)
+
+// WorkerIdStore leases worker ids to generators, so two live nodes never share one
+type WorkerIdStore interface {
+	// Acquire leases a free worker id between 0 and maxWorkerId
+	Acquire(ctx context.Context, maxWorkerId int64) (int64, error)
+	// Renew extends the lease of workerId, it fails with an error wrapping ErrLeaseLost if the lease expired
+	// and may belong to another node
+	Renew(ctx context.Context, workerId int64) error
+	// Release gives workerId back
+	Release(ctx context.Context, workerId int64) error
+}
+
+// ExpiringWorkerIdStore is a WorkerIdStore whose leases expire when not renewed for LeaseTTL
+type ExpiringWorkerIdStore interface {
+	WorkerIdStore
+	LeaseTTL() time.Duration
+}
+
+// default time between two renewals of the lease of a generator built by NewWithStore
+const defaultHeartbeatInterval = 10 * time.Second
+
+// lease is the worker id a generator holds from a WorkerIdStore
+type lease struct {
+	store    WorkerIdStore
+	workerId int64
+	// zero when the store is not an ExpiringWorkerIdStore
+	ttl time.Duration
+	// start of the acquire, renewed is when the last successful renewal began as the time since start
+	start   time.Time
+	renewed atomic.Int64
+	lost    atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewWithStore leases a worker id from store and returns a generator using it, or an error if no id could be
+// leased before ctx is done. The lease is renewed in the background every heartbeat interval (see
+// WithHeartbeatInterval) until Close, which releases it. A failed renewal is logged and retried at the next one.
+// Once Renew reports ErrLeaseLost, or an ExpiringWorkerIdStore went LeaseTTL without a successful renewal,
+// generating fails with ErrLeaseLost, so the generator never issues ids with a worker id another node may hold.
+func NewWithStore(ctx context.Context, store WorkerIdStore, dataCenterId int64, opts ...Option) (*SnowflakeSeqGenerator, error) {
+	// worker id 0 fits every layout, the options are checked before leasing anything
+	r, err := NewSnowflakeSeqGenerator(dataCenterId, 0, opts...)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	workerId, err := store.Acquire(ctx, r.layout.maxWorkerId())
+	if err != nil {
+		return nil, fmt.Errorf("acquire worker id: %w", err)
+	}
+	if workerId < 0 || workerId > r.layout.maxWorkerId() {
+		if rerr := store.Release(context.Background(), workerId); rerr != nil {
+			log.Printf("uidgo: release worker id %d failed: %v", workerId, rerr)
+		}
+		return nil, fmt.Errorf("store leased worker id %d, should between 0 and %d", workerId, r.layout.maxWorkerId())
+	}
+	r.workerId = workerId
+	r.lease = &lease{
+		store:    store,
+		workerId: workerId,
+		start:    start,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if es, ok := store.(ExpiringWorkerIdStore); ok {
+		r.lease.ttl = es.LeaseTTL()
+	}
+	go r.lease.heartbeat(r.heartbeatInterval)
+	return r, nil
+}
+
+func (l *lease) heartbeat(interval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// the lease is extended from before the call, not from its answer
+			began := time.Since(l.start)
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := l.store.Renew(ctx, l.workerId)
+			cancel()
+			if err == nil {
+				l.renewed.Store(int64(began))
+				continue
+			}
+			log.Printf("uidgo: renew worker id %d failed: %v", l.workerId, err)
+			if errors.Is(err, ErrLeaseLost) {
+				l.lost.Store(true)
+				return
+			}
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// check fails once the lease is lost or expired
+func (l *lease) check() error {
+	if l.lost.Load() {
+		return fmt.Errorf("%w: worker id %d", ErrLeaseLost, l.workerId)
+	}
+	if l.ttl > 0 && time.Since(l.start)-time.Duration(l.renewed.Load()) >= l.ttl {
+		return fmt.Errorf("%w: worker id %d not renewed for %v", ErrLeaseLost, l.workerId, l.ttl)
+	}
+	return nil
+}
+
+// release stops the heartbeat and gives the worker id back
+func (l *lease) release() error {
+	close(l.stop)
+	<-l.done
+	return l.store.Release(context.Background(), l.workerId)
+}
+
+var tableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLWorkerIdStore leases worker ids from a SQL table with one row per leased id, the primary key of the table
+// guarantees two nodes never hold the same id. Leases not renewed within TTL expire and the id is reused.
+// Queries use ? placeholders, as MySQL and SQLite drivers expect.
+//
+// Heartbeats are written with the clock of each node and expired with the clock of the acquiring node, so node
+// clocks must agree within a small fraction of TTL, e.g. with NTP. A node whose clock runs more than TTL ahead
+// expires the live leases of other nodes, and both nodes issue ids with the same worker id until the heartbeat
+// of the other node finds its lease lost.
+type SQLWorkerIdStore struct {
+	db    *sql.DB
+	table string
+	// identifies the leases of this store among the ones of other nodes
+	owner string
+
+	// TTL is how long a lease lasts without Renew, the default is 30s
+	TTL time.Duration
+}
+
+// NewSQLWorkerIdStore leases worker ids from table in db, see CreateTable for its schema
+func NewSQLWorkerIdStore(db *sql.DB, table string) (*SQLWorkerIdStore, error) {
+	if !tableNameRegexp.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	owner := make([]byte, 16)
+	if _, err := rand.Read(owner); err != nil {
+		return nil, err
+	}
+	return &SQLWorkerIdStore{
+		db:    db,
+		table: table,
+		owner: hex.EncodeToString(owner),
+		TTL:   30 * time.Second,
+	}, nil
+}
+
+// CreateTable creates the lease table if it doesn't exist
+func (s *SQLWorkerIdStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+s.table+` (
+	worker_id BIGINT NOT NULL PRIMARY KEY,
+	owner VARCHAR(32) NOT NULL,
+	heartbeat BIGINT NOT NULL
+)`)
+	return err
+}
+
+// Acquire leases the lowest worker id up to maxWorkerId that is free or whose lease expired
+func (s *SQLWorkerIdStore) Acquire(ctx context.Context, maxWorkerId int64) (int64, error) {
+	now := time.Now().UnixMilli()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE heartbeat < ?`, now-s.TTL.Milliseconds()); err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT worker_id FROM `+s.table+` ORDER BY worker_id`)
+	if err != nil {
+		return 0, err
+	}
+	leased := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		leased[id] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var insertErr error
+	for id := int64(0); id <= maxWorkerId; id++ {
+		if leased[id] {
+			continue
+		}
+		// another node may insert the same id first, the primary key rejects the second insert
+		_, insertErr = s.db.ExecContext(ctx, `INSERT INTO `+s.table+` (worker_id, owner, heartbeat) VALUES (?, ?, ?)`, id, s.owner, now)
+		if insertErr == nil {
+			return id, nil
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+	}
+	if insertErr != nil {
+		return 0, fmt.Errorf("%w, last insert failed: %v", ErrNoWorkerId, insertErr)
+	}
+	return 0, ErrNoWorkerId
+}
+
+// Renew moves the heartbeat of workerId to now
+func (s *SQLWorkerIdStore) Renew(ctx context.Context, workerId int64) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE `+s.table+` SET heartbeat = ? WHERE worker_id = ? AND owner = ?`,
+		time.Now().UnixMilli(), workerId, s.owner)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("%w: worker id %d", ErrLeaseLost, workerId)
+	}
+	return nil
+}
+
+// LeaseTTL returns TTL, so a generator from NewWithStore stops generating once its lease may have expired
+func (s *SQLWorkerIdStore) LeaseTTL() time.Duration {
+	return s.TTL
+}
+
+// Release deletes the lease of workerId
+func (s *SQLWorkerIdStore) Release(ctx context.Context, workerId int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE worker_id = ? AND owner = ?`, workerId, s.owner)
+	return err
+}
@@ -0,0 +1,246 @@
+package uidgo_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"uidgo"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "uidgo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Ping(); err != nil {
+		t.Skipf("sqlite is not available: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLWorkerIdStore(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	a, err := uidgo.NewSQLWorkerIdStore(db, "worker_ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.CreateTable(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b, err := uidgo.NewSQLWorkerIdStore(db, "worker_ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.TTL = 50 * time.Millisecond
+
+	x, err := a.Acquire(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := b.Acquire(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != 0 || y != 1 {
+		t.Errorf("acquired %d & %d, want the lowest free ids 0 & 1", x, y)
+	}
+	if _, err = b.Acquire(ctx, 1); !errors.Is(err, uidgo.ErrNoWorkerId) {
+		t.Errorf("error(%v) should be ErrNoWorkerId", err)
+	}
+	if err = b.Renew(ctx, x); !errors.Is(err, uidgo.ErrLeaseLost) {
+		t.Errorf("renewing the lease of another node should fail with ErrLeaseLost, got %v", err)
+	}
+
+	// b sees the lease of a expire after its TTL
+	time.Sleep(100 * time.Millisecond)
+	if err = b.Renew(ctx, y); err != nil {
+		t.Error(err)
+	}
+	z, err := b.Acquire(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if z != x {
+		t.Errorf("acquired %d, want the expired id %d", z, x)
+	}
+	if err = a.Renew(ctx, x); err == nil {
+		t.Error("renewing an expired lease taken by another node should fail")
+	}
+
+	if err = b.Release(ctx, z); err != nil {
+		t.Error(err)
+	}
+	if z, err = a.Acquire(ctx, 1); err != nil || z != x {
+		t.Errorf("acquired(%d, %v), want the released id %d", z, err, x)
+	}
+
+	if _, err = uidgo.NewSQLWorkerIdStore(db, "worker_ids; DROP TABLE x"); err == nil {
+		t.Error("an invalid table name should be rejected")
+	}
+}
+
+// fakeStore leases a single worker id
+type fakeStore struct {
+	mu       sync.Mutex
+	leased   bool
+	renewals int
+	released []int64
+	// returned by Renew
+	renewErr error
+	// passed to the last Acquire
+	maxWorkerId int64
+}
+
+func (s *fakeStore) Acquire(ctx context.Context, maxWorkerId int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxWorkerId = maxWorkerId
+	if s.leased {
+		return 0, uidgo.ErrNoWorkerId
+	}
+	s.leased = true
+	return 7, nil
+}
+
+func (s *fakeStore) Renew(ctx context.Context, workerId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renewals++
+	return s.renewErr
+}
+
+func (s *fakeStore) Release(ctx context.Context, workerId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leased = false
+	s.released = append(s.released, workerId)
+	return nil
+}
+
+// expiringStore leases expire after ttl
+type expiringStore struct {
+	fakeStore
+	ttl time.Duration
+}
+
+func (s *expiringStore) LeaseTTL() time.Duration {
+	return s.ttl
+}
+
+// blockingStore never has a free worker id and waits for ctx
+type blockingStore struct {
+	fakeStore
+}
+
+func (s *blockingStore) Acquire(ctx context.Context, maxWorkerId int64) (int64, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestNewWithStore(t *testing.T) {
+	store := new(fakeStore)
+	generator, err := uidgo.NewWithStore(context.Background(), store, 1, uidgo.WithHeartbeatInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := generator.GenerateId2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := generator.Decode(id); c.WorkerId != 7 {
+		t.Errorf("worker id is %d, want the leased 7", c.WorkerId)
+	}
+	if _, err = uidgo.NewWithStore(context.Background(), store, 1); !errors.Is(err, uidgo.ErrNoWorkerId) {
+		t.Errorf("error(%v) should be ErrNoWorkerId while the id is leased", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if err = generator.Close(); err != nil {
+		t.Error(err)
+	}
+	store.mu.Lock()
+	renewals, released := store.renewals, store.released
+	store.mu.Unlock()
+	if renewals < 2 {
+		t.Errorf("renewed %d times, want a heartbeat every 10ms", renewals)
+	}
+	if len(released) != 1 || released[0] != 7 {
+		t.Errorf("released %v, want [7]", released)
+	}
+	if _, err = generator.GenerateId2(); err != uidgo.ErrGeneratorClosed {
+		t.Errorf("error(%v) should be ErrGeneratorClosed after Close", err)
+	}
+	if err = generator.Close(); err != nil {
+		t.Error(err)
+	}
+
+	// 7 doesn't fit the 2 worker id bits of the layout
+	store = new(fakeStore)
+	if _, err = uidgo.NewWithStore(context.Background(), store, 1, uidgo.WithLayout(uidgo.Layout{41, 5, 2, 15})); err == nil {
+		t.Error("a leased worker id outside the layout should be rejected")
+	}
+	if store.maxWorkerId != 3 || len(store.released) != 1 {
+		t.Errorf("acquired up to %d and released %v, want up to 3 and the id released", store.maxWorkerId, store.released)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = uidgo.NewWithStore(ctx, new(blockingStore), 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error(%v) should be the context deadline", err)
+	}
+}
+
+func TestNewWithStore_LeaseLost(t *testing.T) {
+	store := new(fakeStore)
+	generator, err := uidgo.NewWithStore(context.Background(), store, 1, uidgo.WithHeartbeatInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer generator.Close()
+	if _, err = generator.GenerateId2(); err != nil {
+		t.Fatal(err)
+	}
+
+	store.mu.Lock()
+	store.renewErr = fmt.Errorf("%w: taken by another node", uidgo.ErrLeaseLost)
+	store.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if _, err = generator.GenerateId2(); !errors.Is(err, uidgo.ErrLeaseLost) {
+		t.Errorf("error(%v) should be ErrLeaseLost once Renew lost the lease", err)
+	}
+
+	// renewals failing for longer than the TTL
+	expiring := &expiringStore{ttl: 30 * time.Millisecond}
+	expiring.renewErr = errors.New("database is down")
+	generator, err = uidgo.NewWithStore(context.Background(), expiring, 1, uidgo.WithHeartbeatInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer generator.Close()
+	if _, err = generator.GenerateId2(); err != nil {
+		t.Errorf("a fresh lease should generate, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err = generator.GenerateId2(); !errors.Is(err, uidgo.ErrLeaseLost) {
+		t.Errorf("error(%v) should be ErrLeaseLost after the TTL without a renewal", err)
+	}
+
+	// renewing again within the TTL keeps the lease
+	expiring.mu.Lock()
+	expiring.renewErr = nil
+	expiring.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	if _, err = generator.GenerateId2(); err != nil {
+		t.Errorf("a renewed lease should generate, got %v", err)
+	}
+}